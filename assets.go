@@ -0,0 +1,94 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"mime"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+)
+
+// assetTypes is the order the sections appear in the assets file.
+var assetTypes = []string{"pages", "scripts", "styles", "images", "documents", "other"}
+
+var assetExtensions = map[string]string{
+	"": "pages", ".html": "pages", ".htm": "pages", ".xhtml": "pages", ".shtml": "pages",
+	".php": "pages", ".asp": "pages", ".aspx": "pages", ".jsp": "pages", ".cfm": "pages",
+	".js": "scripts", ".mjs": "scripts", ".ts": "scripts", ".jsx": "scripts", ".tsx": "scripts",
+	".css": "styles", ".scss": "styles", ".less": "styles",
+	".png": "images", ".jpg": "images", ".jpeg": "images", ".gif": "images", ".svg": "images",
+	".webp": "images", ".ico": "images", ".bmp": "images", ".avif": "images", ".tif": "images", ".tiff": "images",
+	".pdf": "documents", ".doc": "documents", ".docx": "documents", ".xls": "documents", ".xlsx": "documents",
+	".ppt": "documents", ".pptx": "documents", ".odt": "documents", ".rtf": "documents", ".txt": "documents",
+	".md": "documents", ".csv": "documents", ".json": "documents", ".xml": "documents", ".yaml": "documents",
+}
+
+// assetType classifies a URL. A known Content-Type wins over the extension,
+// since plenty of extensionless URLs serve scripts and images.
+func assetType(rawURL, contentType string) string {
+	if mediaType, _, err := mime.ParseMediaType(contentType); err == nil {
+		switch {
+		case mediaType == "text/html" || mediaType == "application/xhtml+xml":
+			return "pages"
+		case strings.Contains(mediaType, "javascript") || mediaType == "application/ecmascript":
+			return "scripts"
+		case mediaType == "text/css":
+			return "styles"
+		case strings.HasPrefix(mediaType, "image/"):
+			return "images"
+		case mediaType == "application/pdf" || strings.HasPrefix(mediaType, "application/vnd.") ||
+			mediaType == "application/msword" || mediaType == "application/json" ||
+			strings.HasSuffix(mediaType, "xml") || mediaType == "text/plain" || mediaType == "text/csv":
+			return "documents"
+		}
+	}
+
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "other"
+	}
+	if t, ok := assetExtensions[strings.ToLower(path.Ext(u.Path))]; ok {
+		return t
+	}
+	return "other"
+}
+
+// writeAssets writes every unique discovered URL into a section per asset
+// type, using the Content-Type for anything we fetched.
+func writeAssets(filename string, res *Result) error {
+	contentTypes := make(map[string]string)
+	for _, p := range res.Pages {
+		contentTypes[p.URL] = p.Header.Get("Content-Type")
+	}
+
+	buckets := make(map[string][]string)
+	seen := make(map[string]bool)
+	for _, list := range [][]Discovery{res.InScope, res.OutScope} {
+		for _, d := range list {
+			if seen[d.URL] {
+				continue
+			}
+			seen[d.URL] = true
+			t := assetType(d.URL, contentTypes[d.URL])
+			buckets[t] = append(buckets[t], d.URL)
+		}
+	}
+
+	f, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	w := bufio.NewWriter(f)
+	for _, t := range assetTypes {
+		fmt.Fprintf(w, "--%s:---\n", strings.ToUpper(t))
+		for _, u := range buckets[t] {
+			fmt.Fprintln(w, u)
+		}
+		fmt.Fprintln(w)
+	}
+	return w.Flush()
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// parseByteRate parses rates like "2MB/s", "500k" or "1048576" into bytes
+// per second. Units are powers of 1024 and the "/s" is optional.
+func parseByteRate(s string) (int64, error) {
+	v := strings.TrimSpace(s)
+	if strings.HasSuffix(strings.ToUpper(v), "/S") {
+		v = v[:len(v)-2]
+	}
+	n, err := parseByteSize(v)
+	if err != nil {
+		return 0, fmt.Errorf("invalid rate %q", s)
+	}
+	return n, nil
+}
+
+// parseByteSize parses sizes like "500MB", "2g" or "1048576" into bytes.
+// Units are powers of 1024.
+func parseByteSize(s string) (int64, error) {
+	v := strings.ToUpper(strings.TrimSpace(s))
+	v = strings.TrimSuffix(strings.TrimSuffix(v, "IB"), "B")
+
+	multiplier := int64(1)
+	switch {
+	case strings.HasSuffix(v, "K"):
+		multiplier = 1 << 10
+	case strings.HasSuffix(v, "M"):
+		multiplier = 1 << 20
+	case strings.HasSuffix(v, "G"):
+		multiplier = 1 << 30
+	}
+	if multiplier > 1 {
+		v = v[:len(v)-1]
+	}
+
+	n, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid size %q", s)
+	}
+	return int64(n * float64(multiplier)), nil
+}
+
+// byteLimiter is a token bucket on bytes shared by every download. The
+// bucket holds at most one second's worth, so bursts stay short.
+type byteLimiter struct {
+	rate float64
+
+	mu     sync.Mutex
+	tokens float64
+	last   time.Time
+}
+
+func newByteLimiter(bytesPerSec int64) *byteLimiter {
+	return &byteLimiter{rate: float64(bytesPerSec), tokens: float64(bytesPerSec), last: time.Now()}
+}
+
+// wait takes n bytes from the bucket, sleeping for however long it takes
+// the bucket to cover them.
+func (l *byteLimiter) wait(n int) {
+	l.mu.Lock()
+	now := time.Now()
+	l.tokens += now.Sub(l.last).Seconds() * l.rate
+	if l.tokens > l.rate {
+		l.tokens = l.rate
+	}
+	l.last = now
+	l.tokens -= float64(n)
+	deficit := -l.tokens
+	l.mu.Unlock()
+
+	if deficit > 0 {
+		time.Sleep(time.Duration(deficit / l.rate * float64(time.Second)))
+	}
+}
+
+// throughputMeter tracks the busiest one second window of downloading.
+type throughputMeter struct {
+	mu     sync.Mutex
+	second int64
+	bytes  int64
+	peak   int64
+}
+
+func (m *throughputMeter) add(n int) {
+	sec := time.Now().Unix()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if sec != m.second {
+		m.second, m.bytes = sec, 0
+	}
+	m.bytes += int64(n)
+	if m.bytes > m.peak {
+		m.peak = m.bytes
+	}
+}
+
+func (m *throughputMeter) Peak() int64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.peak
+}
+
+// meteredReader counts what is read through it and, with a limiter, paces
+// it to the configured bandwidth.
+type meteredReader struct {
+	r       io.Reader
+	limiter *byteLimiter
+	meter   *throughputMeter
+}
+
+func (m *meteredReader) Read(p []byte) (int, error) {
+	if m.limiter != nil && float64(len(p)) > m.limiter.rate/4 {
+		// Small reads keep a slow limit smooth instead of sleeping for
+		// seconds after every buffer.
+		p = p[:int(m.limiter.rate/4)+1]
+	}
+	n, err := m.r.Read(p)
+	if m.limiter != nil {
+		m.limiter.wait(n)
+	}
+	m.meter.add(n)
+	return n, err
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+	"time"
+)
+
+const burpTimeFormat = "Mon Jan 02 15:04:05 MST 2006"
+
+type burpItems struct {
+	XMLName     xml.Name   `xml:"items"`
+	BurpVersion string     `xml:"burpVersion,attr"`
+	ExportTime  string     `xml:"exportTime,attr"`
+	Items       []burpItem `xml:"item"`
+}
+
+type burpItem struct {
+	Time           string      `xml:"time"`
+	URL            burpCDATA   `xml:"url"`
+	Host           burpHost    `xml:"host"`
+	Port           string      `xml:"port"`
+	Protocol       string      `xml:"protocol"`
+	Method         burpCDATA   `xml:"method"`
+	Path           burpCDATA   `xml:"path"`
+	Extension      string      `xml:"extension"`
+	Request        burpPayload `xml:"request"`
+	Status         int         `xml:"status"`
+	ResponseLength int         `xml:"responselength"`
+	MimeType       string      `xml:"mimetype"`
+	Response       burpPayload `xml:"response"`
+	Comment        string      `xml:"comment"`
+}
+
+type burpCDATA struct {
+	Value string `xml:",cdata"`
+}
+
+type burpHost struct {
+	IP   string `xml:"ip,attr"`
+	Name string `xml:",chardata"`
+}
+
+type burpPayload struct {
+	Base64 bool   `xml:"base64,attr"`
+	Value  string `xml:",cdata"`
+}
+
+// writeBurpXML writes the fetched pages in the layout of Burp's
+// "Save selected items" export. Request and response are base64 encoded
+// only when bodies were kept, otherwise just the headers are written.
+func writeBurpXML(filename string, pages []Page, includeBodies bool) error {
+	now := time.Now().Format(burpTimeFormat)
+	doc := burpItems{BurpVersion: "2023.1", ExportTime: now}
+
+	for _, p := range pages {
+		u, err := url.Parse(p.URL)
+		if err != nil {
+			continue
+		}
+
+		port := u.Port()
+		if port == "" {
+			port = "80"
+			if u.Scheme == "https" {
+				port = "443"
+			}
+		}
+
+		ext := strings.TrimPrefix(path.Ext(u.Path), ".")
+		if ext == "" {
+			ext = "null"
+		}
+
+		req := burpRawRequest(u, p)
+		resp := burpRawResponse(p, includeBodies)
+		item := burpItem{
+			Time:           p.FetchedAt.Format(burpTimeFormat),
+			URL:            burpCDATA{p.URL},
+			Host:           burpHost{Name: u.Hostname()},
+			Port:           port,
+			Protocol:       u.Scheme,
+			Method:         burpCDATA{p.Method},
+			Path:           burpCDATA{u.RequestURI()},
+			Extension:      ext,
+			Status:         p.StatusCode,
+			ResponseLength: p.Size,
+			MimeType:       burpMimeType(p.Header.Get("Content-Type")),
+		}
+		if includeBodies {
+			item.Request = burpPayload{true, base64.StdEncoding.EncodeToString(req)}
+			item.Response = burpPayload{true, base64.StdEncoding.EncodeToString(resp)}
+		} else {
+			item.Request = burpPayload{false, string(req)}
+			item.Response = burpPayload{false, string(resp)}
+		}
+		doc.Items = append(doc.Items, item)
+	}
+
+	f, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	f.WriteString(xml.Header)
+	enc := xml.NewEncoder(f)
+	enc.Indent("", "  ")
+	if err := enc.Encode(doc); err != nil {
+		return err
+	}
+	_, err = f.WriteString("\n")
+	return err
+}
+
+func burpRawRequest(u *url.URL, p Page) []byte {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "%s %s HTTP/1.1\r\nHost: %s\r\n", p.Method, u.RequestURI(), u.Host)
+	p.RequestHeader.Write(&b)
+	b.WriteString("\r\n")
+	return b.Bytes()
+}
+
+func burpRawResponse(p Page, includeBody bool) []byte {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "HTTP/1.1 %d %s\r\n", p.StatusCode, http.StatusText(p.StatusCode))
+	p.Header.Write(&b)
+	b.WriteString("\r\n")
+	if includeBody {
+		b.Write(p.Body)
+	}
+	return b.Bytes()
+}
+
+func burpMimeType(contentType string) string {
+	ct := strings.ToLower(contentType)
+	switch {
+	case strings.Contains(ct, "html"):
+		return "HTML"
+	case strings.Contains(ct, "javascript"):
+		return "script"
+	case strings.Contains(ct, "json"):
+		return "JSON"
+	case strings.Contains(ct, "css"):
+		return "CSS"
+	case strings.Contains(ct, "xml"):
+		return "XML"
+	case strings.HasPrefix(ct, "image/"):
+		return "image"
+	case strings.HasPrefix(ct, "text/"):
+		return "text"
+	}
+	return ""
+}
@@ -0,0 +1,221 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// defaultCacheMaxAge is how long a cached response is revalidated rather
+// than fetched from scratch.
+const defaultCacheMaxAge = 7 * 24 * time.Hour
+
+type cacheEntry struct {
+	URL          string      `json:"url"`
+	ETag         string      `json:"etag,omitempty"`
+	LastModified string      `json:"last_modified,omitempty"`
+	BodyHash     string      `json:"body_hash"`
+	Header       http.Header `json:"header"`
+	StoredAt     time.Time   `json:"stored_at"`
+}
+
+// ResponseCache is an http.RoundTripper that keeps GET responses carrying
+// an ETag or Last-Modified in Dir between runs. Later requests for the same
+// URL are made conditional, and a 304 is answered with the cached 200 so
+// the crawler still sees the page's links without downloading it again.
+type ResponseCache struct {
+	Dir       string
+	MaxAge    time.Duration
+	Transport http.RoundTripper
+}
+
+// NewResponseCache opens the cache in dir and evicts entries older than
+// maxAge. A zero maxAge keeps entries forever.
+func NewResponseCache(dir string, maxAge time.Duration, transport http.RoundTripper) (*ResponseCache, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	c := &ResponseCache{Dir: dir, MaxAge: maxAge, Transport: transport}
+	if err := c.prune(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func (c *ResponseCache) prune() error {
+	if c.MaxAge <= 0 {
+		return nil
+	}
+	files, err := os.ReadDir(c.Dir)
+	if err != nil {
+		return err
+	}
+	for _, file := range files {
+		if !strings.HasSuffix(file.Name(), ".json") {
+			continue
+		}
+		info, err := file.Info()
+		if err != nil || time.Since(info.ModTime()) <= c.MaxAge {
+			continue
+		}
+		name := filepath.Join(c.Dir, file.Name())
+		os.Remove(name)
+		os.Remove(strings.TrimSuffix(name, ".json") + ".body")
+	}
+	return nil
+}
+
+func (c *ResponseCache) paths(u string) (meta, body string) {
+	name := filepath.Join(c.Dir, hashName(u, ""))
+	return name + ".json", name + ".body"
+}
+
+func (c *ResponseCache) load(u string) *cacheEntry {
+	metaPath, _ := c.paths(u)
+	data, err := os.ReadFile(metaPath)
+	if err != nil {
+		return nil
+	}
+	var e cacheEntry
+	if json.Unmarshal(data, &e) != nil || e.URL != u {
+		return nil
+	}
+	if c.MaxAge > 0 && time.Since(e.StoredAt) > c.MaxAge {
+		return nil
+	}
+	return &e
+}
+
+// drop forgets the entry for u.
+func (c *ResponseCache) drop(u string) {
+	metaPath, bodyPath := c.paths(u)
+	os.Remove(metaPath)
+	os.Remove(bodyPath)
+}
+
+func (c *ResponseCache) store(e *cacheEntry, body []byte) {
+	metaPath, bodyPath := c.paths(e.URL)
+	data, err := json.Marshal(e)
+	if err != nil {
+		return
+	}
+	// The body goes first so an entry never points at a missing body.
+	if body != nil {
+		if err := os.WriteFile(bodyPath, body, 0644); err != nil {
+			errorf("Could not cache %s: %v", e.URL, err)
+			return
+		}
+	}
+	if err := os.WriteFile(metaPath, data, 0644); err != nil {
+		errorf("Could not cache %s: %v", e.URL, err)
+	}
+}
+
+func (c *ResponseCache) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Method != "GET" {
+		return c.Transport.RoundTrip(req)
+	}
+	u := req.URL.String()
+	plain := req
+
+	entry := c.load(u)
+	if entry != nil {
+		req = req.Clone(req.Context())
+		if entry.ETag != "" {
+			req.Header.Set("If-None-Match", entry.ETag)
+		}
+		if entry.LastModified != "" {
+			req.Header.Set("If-Modified-Since", entry.LastModified)
+		}
+	}
+
+	resp, err := c.Transport.RoundTrip(req)
+	if err != nil {
+		return resp, err
+	}
+
+	if resp.StatusCode == http.StatusNotModified && entry != nil {
+		_, bodyPath := c.paths(u)
+		body, err := os.ReadFile(bodyPath)
+		if err == nil && bodyHash(body) == entry.BodyHash {
+			resp.Body.Close()
+			verbosef("Not modified, using cached copy of %s", u)
+			entry.StoredAt = time.Now()
+			c.store(entry, nil)
+			return &http.Response{
+				Status:        "200 OK",
+				StatusCode:    http.StatusOK,
+				Proto:         resp.Proto,
+				ProtoMajor:    resp.ProtoMajor,
+				ProtoMinor:    resp.ProtoMinor,
+				Header:        entry.Header.Clone(),
+				Body:          io.NopCloser(bytes.NewReader(body)),
+				ContentLength: int64(len(body)),
+				Request:       req,
+			}, nil
+		}
+		// The cached body is gone or damaged, so the 304 is no use and
+		// the entry would fail the same way in every later run.
+		resp.Body.Close()
+		verbosef("Cached copy of %s is unusable, fetching it again", u)
+		c.drop(u)
+		req = plain
+		resp, err = c.Transport.RoundTrip(req)
+		if err != nil {
+			return resp, err
+		}
+	}
+
+	etag, lastModified := resp.Header.Get("ETag"), resp.Header.Get("Last-Modified")
+	if resp.StatusCode == http.StatusOK && (etag != "" || lastModified != "") {
+		resp.Body = &cacheBodyReader{
+			ReadCloser: resp.Body,
+			cache:      c,
+			entry: &cacheEntry{
+				URL:          u,
+				ETag:         etag,
+				LastModified: lastModified,
+				Header:       resp.Header.Clone(),
+			},
+		}
+	}
+	return resp, nil
+}
+
+// cacheBodyReader stores the body once it has been read to the end. Bodies
+// that are abandoned part way are not cached.
+type cacheBodyReader struct {
+	io.ReadCloser
+	cache *ResponseCache
+	entry *cacheEntry
+	buf   bytes.Buffer
+	done  bool
+}
+
+func (b *cacheBodyReader) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.buf.Write(p[:n])
+	if err == io.EOF && !b.done {
+		b.done = true
+		body := b.buf.Bytes()
+		b.entry.BodyHash = bodyHash(body)
+		b.entry.StoredAt = time.Now()
+		b.cache.store(b.entry, body)
+	}
+	return n, err
+}
+
+func bodyHash(body []byte) string {
+	sum := sha1.Sum(body)
+	return hex.EncodeToString(sum[:])
+}
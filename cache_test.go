@@ -0,0 +1,67 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+)
+
+func TestCacheRefetchesUnusableBody(t *testing.T) {
+	const page = `<html><body><a href="/next">next</a></body></html>`
+	for name, damage := range map[string]func(bodyPath string) error{
+		"deleted":   os.Remove,
+		"corrupted": func(p string) error { return os.WriteFile(p, []byte("garbage"), 0644) },
+	} {
+		t.Run(name, func(t *testing.T) {
+			var notModified int
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("ETag", `"v1"`)
+				if r.Header.Get("If-None-Match") == `"v1"` {
+					notModified++
+					w.WriteHeader(http.StatusNotModified)
+					return
+				}
+				w.Write([]byte(page))
+			}))
+			defer srv.Close()
+
+			cache, err := NewResponseCache(t.TempDir(), 0, http.DefaultTransport)
+			if err != nil {
+				t.Fatal(err)
+			}
+			client := &http.Client{Transport: cache}
+			get := func() (int, string) {
+				resp, err := client.Get(srv.URL + "/")
+				if err != nil {
+					t.Fatalf("get: %v", err)
+				}
+				defer resp.Body.Close()
+				body, _ := io.ReadAll(resp.Body)
+				return resp.StatusCode, string(body)
+			}
+
+			get()
+			_, bodyPath := cache.paths(srv.URL + "/")
+			if err := damage(bodyPath); err != nil {
+				t.Fatal(err)
+			}
+			if status, body := get(); status != http.StatusOK || body != page {
+				t.Fatalf("after damage got %d %q, want 200 with the page", status, body)
+			}
+			if notModified != 1 {
+				t.Fatalf("server sent %d 304s, want 1", notModified)
+			}
+
+			// The refetched page replaced the entry, so the next run is
+			// served from the cache again.
+			if status, body := get(); status != http.StatusOK || body != page {
+				t.Errorf("after refetch got %d %q, want 200 with the page", status, body)
+			}
+			if notModified != 2 {
+				t.Errorf("server sent %d 304s, want 2", notModified)
+			}
+		})
+	}
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"errors"
+	"net"
+	"sort"
+	"strings"
+
+	"github.com/chromedp/chromedp"
+)
+
+// ChromeOptions returns the Chrome flags that make the browser pass go
+// where f's requests go. It fails when Chrome can't be made to, so the
+// caller can skip the pass rather than let it reach the target directly.
+func (f *HTTPFetcher) ChromeOptions() ([]chromedp.ExecAllocatorOption, error) {
+	var opts []chromedp.ExecAllocatorOption
+	if f.proxy != nil {
+		// --proxy-server takes no credentials, and Chrome would only ask
+		// for them in a dialog no one is there to answer.
+		if f.proxy.User != nil {
+			return nil, errors.New("Chrome can't authenticate to the proxy")
+		}
+		proxy := *f.proxy
+		if proxy.Scheme == "socks5h" {
+			// Chrome always lets a SOCKS5 proxy resolve the host.
+			proxy.Scheme = "socks5"
+		}
+		opts = append(opts, chromedp.ProxyServer(proxy.Scheme+"://"+proxy.Host))
+	}
+	if f.proxyInsecure {
+		opts = append(opts, chromedp.IgnoreCertErrors)
+	}
+
+	f.dns.mu.Lock()
+	defer f.dns.mu.Unlock()
+	// Chrome has no flag for a plain DNS server, only its own DoH
+	// settings.
+	if f.dns.resolver != net.DefaultResolver {
+		return nil, errors.New("Chrome can't be pointed at the -dns server")
+	}
+	if len(f.dns.overrides) > 0 {
+		var rules []string
+		for host, ip := range f.dns.overrides {
+			if strings.Contains(ip, ":") {
+				ip = "[" + ip + "]"
+			}
+			rules = append(rules, "MAP "+host+" "+ip)
+		}
+		sort.Strings(rules)
+		opts = append(opts, chromedp.Flag("host-resolver-rules", strings.Join(rules, ", ")))
+	}
+	return opts, nil
+}
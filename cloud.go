@@ -0,0 +1,106 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// CloudBucket is a cloud storage bucket (or Azure storage account and
+// container) referenced by URL on the page or script Source.
+type CloudBucket struct {
+	Provider string
+	Bucket   string
+	URL      string
+	Source   string
+}
+
+// bucketSchemeRegex finds s3:// and gs:// references, which the http(s)
+// regex used on scripts doesn't match.
+var bucketSchemeRegex = regexp.MustCompile(`\b(?:s3|gs)://[a-z0-9][a-z0-9._\-]{1,62}[^\s"'<>]*`)
+
+// s3HostRegex matches the S3 endpoints, with the bucket in front for
+// virtual-hosted style URLs: s3.amazonaws.com, s3.eu-west-1.amazonaws.com,
+// s3-eu-west-1.amazonaws.com, s3.dualstack.us-east-1.amazonaws.com and
+// the s3-website variants.
+var s3HostRegex = regexp.MustCompile(`^(?:(.+)\.)?s3(?:[.\-](?:dualstack|website|[a-z]{2}-[a-z]+-\d))*(?:[.\-][a-z]{2}-[a-z]+-\d)?\.amazonaws\.com(?:\.cn)?$`)
+
+// cloudBucket returns the provider and bucket a URL points into, or "" if
+// it isn't a cloud storage URL.
+func cloudBucket(u string) (provider, bucket string) {
+	parsed, err := url.Parse(u)
+	if err != nil {
+		return "", ""
+	}
+	host := strings.ToLower(parsed.Hostname())
+	segments := strings.Split(strings.TrimPrefix(parsed.Path, "/"), "/")
+	first := segments[0]
+
+	switch strings.ToLower(parsed.Scheme) {
+	case "s3":
+		return "s3", host
+	case "gs":
+		return "gcs", host
+	}
+
+	switch {
+	case s3HostRegex.MatchString(host):
+		if b := s3HostRegex.FindStringSubmatch(host)[1]; b != "" {
+			return "s3", b
+		}
+		return "s3", first
+	case host == "storage.googleapis.com" || host == "storage.cloud.google.com":
+		return "gcs", first
+	case strings.HasSuffix(host, ".storage.googleapis.com"):
+		return "gcs", strings.TrimSuffix(host, ".storage.googleapis.com")
+	case host == "firebasestorage.googleapis.com" || host == "www.googleapis.com" && first == "storage":
+		// /v0/b/<bucket>/o/... and /storage/v1/b/<bucket>/o/...
+		for i := 0; i+1 < len(segments); i++ {
+			if segments[i] == "b" {
+				return "gcs", segments[i+1]
+			}
+		}
+	case strings.HasSuffix(host, ".blob.core.windows.net"):
+		account := strings.TrimSuffix(host, ".blob.core.windows.net")
+		if first != "" {
+			return "azure", account + "/" + first
+		}
+		return "azure", account
+	}
+	return "", ""
+}
+
+// checkCloud records u if it points into cloud storage, once per bucket.
+func (c *Crawler) checkCloud(u, source string) {
+	provider, bucket := cloudBucket(u)
+	if provider == "" || bucket == "" {
+		return
+	}
+	c.resultMu.Lock()
+	defer c.resultMu.Unlock()
+	key := provider + " " + bucket
+	if c.bucketsSeen[key] {
+		return
+	}
+	c.bucketsSeen[key] = true
+	verbosef("Cloud storage bucket found: %s %s", provider, bucket)
+	c.result.Buckets = append(c.result.Buckets, CloudBucket{Provider: provider, Bucket: bucket, URL: u, Source: source})
+}
+
+func writeCloud(filename string, buckets []CloudBucket) error {
+	f, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	f.WriteString("--CLOUD STORAGE:---\n")
+	for _, b := range buckets {
+		if _, err := fmt.Fprintf(f, "%s %s %s %s\n", b.Provider, b.Bucket, b.URL, b.Source); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"regexp"
+
+	"golang.org/x/net/html"
+)
+
+// Tags for URLs found outside the live markup.
+const (
+	viaComment  = "comment"
+	viaNoscript = "noscript"
+)
+
+// commentPathRegex matches root-relative paths in free text, where they
+// follow whitespace, a quote, "=" or "(".
+var commentPathRegex = regexp.MustCompile(`(?:^|[\s"'=(])(/[^\s"'<>()]+)`)
+
+// extractHidden finds URLs that extractLinks can't see: in HTML comments,
+// where commented-out links and staging URLs tend to be left behind, and
+// inside <noscript>, which html.Parse keeps as raw text.
+func (c *Crawler) extractHidden(base string, n *html.Node) (comments, noscript []string) {
+	switch {
+	case n.Type == html.CommentNode:
+		comments = append(comments, scriptURLRegex.FindAllString(n.Data, -1)...)
+		for _, m := range commentPathRegex.FindAllStringSubmatch(n.Data, -1) {
+			if isScriptPath(m[1]) {
+				comments = append(comments, c.formatURL(base, m[1]))
+			}
+		}
+	case n.Type == html.ElementNode && n.Data == "noscript":
+		noscript = append(noscript, c.fragmentLinks(base, textContent(n))...)
+	}
+
+	for child := n.FirstChild; child != nil; child = child.NextSibling {
+		cs, ns := c.extractHidden(base, child)
+		comments = append(comments, cs...)
+		noscript = append(noscript, ns...)
+	}
+	return comments, noscript
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	defaultMinWorkers = 1
+	defaultMaxWorkers = 20
+
+	// adaptiveWindow is how many requests the controller looks at before
+	// deciding whether to change the limit.
+	adaptiveWindow = 20
+)
+
+// adaptiveLimiter caps how many workers fetch at once and moves the cap
+// between min and max based on how the server is coping. After every
+// adaptiveWindow requests it compares their median latency with the best
+// median seen so far: close to it means there is headroom and the limit
+// goes up by one, twice it (or too many errors) halves the limit.
+type adaptiveLimiter struct {
+	min, max int
+
+	mu       sync.Mutex
+	cond     *sync.Cond
+	limit    int
+	active   int
+	samples  []time.Duration
+	failures int
+	baseline time.Duration
+	closed   bool
+}
+
+func newAdaptiveLimiter(lo, hi int) *adaptiveLimiter {
+	lo = max(lo, 1)
+	hi = max(hi, lo)
+	l := &adaptiveLimiter{min: lo, max: hi, limit: lo}
+	l.cond = sync.NewCond(&l.mu)
+	return l
+}
+
+// acquire blocks until fewer than limit workers are active. It returns
+// false, without taking a slot, once the limiter is closed.
+func (l *adaptiveLimiter) acquire() bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for l.active >= l.limit && !l.closed {
+		l.cond.Wait()
+	}
+	if l.closed {
+		return false
+	}
+	l.active++
+	return true
+}
+
+func (l *adaptiveLimiter) release() {
+	l.mu.Lock()
+	l.active--
+	l.mu.Unlock()
+	l.cond.Signal()
+}
+
+// close wakes every worker waiting in acquire so it can exit.
+func (l *adaptiveLimiter) close() {
+	l.mu.Lock()
+	l.closed = true
+	l.mu.Unlock()
+	l.cond.Broadcast()
+}
+
+// observe records how long a request took and whether it failed, which
+// includes the server answering 429 or 5xx.
+func (l *adaptiveLimiter) observe(latency time.Duration, failed bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.samples = append(l.samples, latency)
+	if failed {
+		l.failures++
+	}
+	if len(l.samples) < adaptiveWindow {
+		return
+	}
+
+	sort.Slice(l.samples, func(i, j int) bool { return l.samples[i] < l.samples[j] })
+	median := l.samples[len(l.samples)/2]
+	errorRate := float64(l.failures) / float64(len(l.samples))
+	l.samples = l.samples[:0]
+	l.failures = 0
+	if l.baseline == 0 || median < l.baseline {
+		l.baseline = median
+	}
+
+	old := l.limit
+	switch {
+	case errorRate > 0.2 || median > 2*l.baseline:
+		l.limit = max(l.min, l.limit/2)
+	case median < l.baseline*5/4:
+		l.limit = min(l.max, l.limit+1)
+	}
+	if l.limit != old {
+		verbosef("Concurrency %d -> %d (median latency %s, %.0f%% errors)", old, l.limit, median, errorRate*100)
+		l.cond.Broadcast()
+	}
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// loadConfig applies a YAML or JSON config file to fs. The file's keys are
+// the flag names themselves, so every flag can be set from a config and the
+// two never drift apart:
+//
+//	url: https://example.com/
+//	inscope: [example.com, example.net]
+//	H: ["X-Bug-Bounty: me", "Authorization: Bearer abc"]
+//
+// Flags given on the command line always win over the file.
+func loadConfig(filename string, fs *flag.FlagSet) error {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+
+	// YAML is a superset of JSON, so one decoder covers both formats.
+	var values map[string]interface{}
+	if err := yaml.Unmarshal(data, &values); err != nil {
+		return fmt.Errorf("parsing %s: %w", filename, err)
+	}
+
+	fromCLI := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) { fromCLI[f.Name] = true })
+
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		f := fs.Lookup(name)
+		if f == nil {
+			return fmt.Errorf("%s: unknown setting %q", filename, name)
+		}
+		if fromCLI[name] || values[name] == nil {
+			continue
+		}
+
+		var err error
+		switch v := values[name].(type) {
+		case []interface{}:
+			if isRepeatable(f.Value) {
+				for _, item := range v {
+					if err = f.Value.Set(fmt.Sprint(item)); err != nil {
+						break
+					}
+				}
+			} else {
+				items := make([]string, len(v))
+				for i, item := range v {
+					items[i] = fmt.Sprint(item)
+				}
+				err = f.Value.Set(strings.Join(items, ","))
+			}
+		default:
+			err = f.Value.Set(fmt.Sprint(v))
+		}
+		if err != nil {
+			return fmt.Errorf("%s: invalid value for %s: %w", filename, name, err)
+		}
+	}
+	return nil
+}
+
+// isRepeatable reports whether a flag takes one value per use rather than
+// a comma-separated list.
+func isRepeatable(v flag.Value) bool {
+	switch v.(type) {
+	case *headerFlags, *resolveFlags, *priorityFlags:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"sync/atomic"
+	"testing"
+)
+
+// TestCrawlKeepsSessionCookie crawls a site whose second page is only
+// served to a client holding the cookie the first page sets.
+func TestCrawlKeepsSessionCookie(t *testing.T) {
+	var authorized, seeded atomic.Bool
+	srv, _ := newCountingServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if c, err := r.Cookie("seed"); err == nil && c.Value == "s1" {
+			seeded.Store(true)
+		}
+		switch r.URL.Path {
+		case "/":
+			http.SetCookie(w, &http.Cookie{Name: "session", Value: "abc", Path: "/"})
+			w.Header().Set("Content-Type", "text/html")
+			w.Write([]byte(`<a href="/private">private</a>`))
+		case "/private":
+			if c, err := r.Cookie("session"); err != nil || c.Value != "abc" {
+				http.Error(w, "login required", http.StatusForbidden)
+				return
+			}
+			authorized.Store(true)
+			w.Header().Set("Content-Type", "text/html")
+			w.Write([]byte(`<p>welcome</p>`))
+		default:
+			http.NotFound(w, r)
+		}
+	}), false)
+
+	crawler := NewCrawler([]string{srv.URL}, nil)
+	fetcher := NewHTTPFetcher()
+	fetcher.InScope = crawler.isInScope
+	crawler.Fetcher = fetcher
+
+	// As -cookie does, seed a cookie for the seed URL.
+	cookies, err := parseCookieHeader("seed=s1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	seed, _ := url.Parse(srv.URL + "/")
+	fetcher.Client.Jar.SetCookies(seed, cookies)
+
+	if _, err := crawler.Run(context.Background(), []string{srv.URL + "/"}); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if !seeded.Load() {
+		t.Error("seeded cookie was never sent")
+	}
+	if !authorized.Load() {
+		t.Error("page 2 was not requested with the cookie set by page 1")
+	}
+}
+
+func TestParseCookieHeader(t *testing.T) {
+	cookies, err := parseCookieHeader(" a=1; b = two=2 ;; c=")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"a=1", "b=two=2", "c="}
+	if len(cookies) != len(want) {
+		t.Fatalf("got %d cookies, want %d", len(cookies), len(want))
+	}
+	for i, c := range cookies {
+		if got := c.Name + "=" + c.Value; got != want[i] {
+			t.Errorf("cookie %d = %q, want %q", i, got, want[i])
+		}
+	}
+	if _, err := parseCookieHeader("a=1; novalue"); err == nil {
+		t.Error("cookie without = was accepted")
+	}
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"mime"
+	"regexp"
+	"strings"
+)
+
+var (
+	cssCommentRegex = regexp.MustCompile(`(?s)/\*.*?\*/`)
+	cssURLRegex     = regexp.MustCompile(`(?i)url\(\s*(?:"([^"]*)"|'([^']*)'|([^)"'\s]*))\s*\)`)
+	cssImportRegex  = regexp.MustCompile(`(?i)@import\s+(?:"([^"]*)"|'([^']*)')`)
+)
+
+// cssLinks returns the url(...) and @import targets in a stylesheet, a
+// <style> element or a style attribute, as written. data: URIs and
+// references to fragments in the same document (url(#gradient)) are left
+// out.
+func cssLinks(css string) []string {
+	css = cssCommentRegex.ReplaceAllString(css, "")
+
+	var links []string
+	for _, re := range []*regexp.Regexp{cssImportRegex, cssURLRegex} {
+		for _, m := range re.FindAllStringSubmatch(css, -1) {
+			link := strings.TrimSpace(strings.Join(m[1:], ""))
+			if link == "" || strings.HasPrefix(link, "#") || strings.HasPrefix(strings.ToLower(link), "data:") {
+				continue
+			}
+			links = append(links, link)
+		}
+	}
+	return links
+}
+
+// isCSS reports whether a response is a stylesheet, going by its content
+// type or its URL, since plenty of servers send .css files as text/plain.
+func isCSS(contentType, u string) bool {
+	if mediaType, _, err := mime.ParseMediaType(contentType); err == nil && mediaType == "text/css" {
+		return true
+	}
+	if i := strings.IndexAny(u, "?#"); i >= 0 {
+		u = u[:i]
+	}
+	return strings.HasSuffix(strings.ToLower(u), ".css")
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"database/sql"
+	"time"
+
+	_ "modernc.org/sqlite"
+)
+
+// dbBatchSize is how many rows go into one transaction. SQLite commits are
+// slow (each one syncs the file), so inserting row by row would make the
+// writer the bottleneck of a large crawl.
+const dbBatchSize = 500
+
+const dbSchema = `
+CREATE TABLE IF NOT EXISTS pages (
+	url            TEXT PRIMARY KEY,
+	scope          TEXT NOT NULL,
+	source         TEXT,
+	via            TEXT,
+	depth          INTEGER NOT NULL,
+	status         INTEGER,
+	proto          TEXT,
+	server         TEXT,
+	powered_by     TEXT,
+	content_type   TEXT,
+	content_length INTEGER,
+	duration_ms    REAL,
+	discovered_at  TEXT,
+	fetched_at     TEXT
+);
+CREATE TABLE IF NOT EXISTS links (
+	source TEXT NOT NULL,
+	target TEXT NOT NULL,
+	PRIMARY KEY (source, target)
+);
+CREATE INDEX IF NOT EXISTS links_target ON links (target);
+`
+
+// A fetched row replaces whatever is there, a discovered-only row never
+// overwrites one that was fetched.
+const (
+	dbInsertFetched    = `INSERT OR REPLACE INTO pages VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+	dbInsertDiscovered = `INSERT OR IGNORE INTO pages VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+	dbInsertLink       = `INSERT OR IGNORE INTO links VALUES (?, ?)`
+)
+
+// dbWriter stores the result stream in a SQLite database. Like the other
+// sinks it runs on the stream goroutine, so it needs no locking. Rows are
+// buffered and written dbBatchSize at a time; after the first error it
+// drops everything so the crawl never blocks.
+type dbWriter struct {
+	db      *sql.DB
+	pending []URLResult
+	err     error
+}
+
+func newDBWriter(path string) (*dbWriter, error) {
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := db.Exec(dbSchema); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &dbWriter{db: db}, nil
+}
+
+func (d *dbWriter) Add(r URLResult) {
+	if d.err != nil {
+		return
+	}
+	d.pending = append(d.pending, r)
+	if len(d.pending) >= dbBatchSize {
+		d.flush()
+	}
+}
+
+func (d *dbWriter) flush() {
+	if d.err != nil || len(d.pending) == 0 {
+		return
+	}
+	d.err = d.inTx(func(tx *sql.Tx) error {
+		fetched, err := tx.Prepare(dbInsertFetched)
+		if err != nil {
+			return err
+		}
+		discovered, err := tx.Prepare(dbInsertDiscovered)
+		if err != nil {
+			return err
+		}
+		for _, r := range d.pending {
+			stmt := discovered
+			if r.FetchedAt != nil {
+				stmt = fetched
+			}
+			_, err := stmt.Exec(r.URL, r.Scope, nullString(r.Source), nullString(r.Via), r.Depth,
+				nullInt(int64(r.Status)), nullString(r.Proto), nullString(r.Server), nullString(r.PoweredBy),
+				nullString(r.ContentType), nullInt(r.ContentLength), nullFloat(r.DurationMS),
+				dbTime(&r.DiscoveredAt), dbTime(r.FetchedAt))
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	d.pending = d.pending[:0]
+}
+
+// WriteLinks stores every edge of the link graph. The stream only carries
+// the first page each URL was found on, so the edges are taken from the
+// graph once the crawl is over.
+func (d *dbWriter) WriteLinks(g *LinkGraph) error {
+	d.flush()
+	if d.err != nil {
+		return d.err
+	}
+	for start := 0; start < len(g.Edges); start += dbBatchSize {
+		batch := g.Edges[start:min(start+dbBatchSize, len(g.Edges))]
+		err := d.inTx(func(tx *sql.Tx) error {
+			stmt, err := tx.Prepare(dbInsertLink)
+			if err != nil {
+				return err
+			}
+			for _, e := range batch {
+				if _, err := stmt.Exec(g.Nodes[e.From].URL, g.Nodes[e.To].URL); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Close writes any buffered rows and closes the database. It returns the
+// first error seen by the writer.
+func (d *dbWriter) Close() error {
+	d.flush()
+	if err := d.db.Close(); d.err == nil {
+		d.err = err
+	}
+	return d.err
+}
+
+func (d *dbWriter) inTx(fn func(*sql.Tx) error) error {
+	tx, err := d.db.Begin()
+	if err != nil {
+		return err
+	}
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+func nullString(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
+}
+
+func nullInt(n int64) sql.NullInt64 {
+	return sql.NullInt64{Int64: n, Valid: n != 0}
+}
+
+func nullFloat(f float64) sql.NullFloat64 {
+	return sql.NullFloat64{Float64: f, Valid: f != 0}
+}
+
+func dbTime(t *time.Time) sql.NullString {
+	if t == nil || t.IsZero() {
+		return sql.NullString{}
+	}
+	return sql.NullString{String: t.UTC().Format(time.RFC3339Nano), Valid: true}
+}
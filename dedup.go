@@ -0,0 +1,121 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// Duplicate is a page whose content matched one crawled before it, or,
+// with Canonical set, whose <link rel="canonical"> points to a page that
+// was already crawled or claimed by another page.
+type Duplicate struct {
+	URL       string
+	Original  string
+	Hash      string
+	Canonical bool
+}
+
+var whitespaceRegex = regexp.MustCompile(`\s+`)
+
+// contentHash hashes body with runs of whitespace collapsed, so pages that
+// only differ in indentation or line endings still match.
+func contentHash(body []byte) string {
+	sum := sha256.Sum256(whitespaceRegex.ReplaceAll(body, []byte(" ")))
+	return hex.EncodeToString(sum[:])
+}
+
+// checkDuplicate records body's hash for pageURL and, if another page had
+// the same content first, records pageURL as its duplicate and returns
+// true.
+func (c *Crawler) checkDuplicate(pageURL string, body []byte) bool {
+	hash := contentHash(body)
+	c.Mutex.Lock()
+	original, seen := c.contentHashes[hash]
+	if !seen {
+		c.contentHashes[hash] = pageURL
+	}
+	c.Mutex.Unlock()
+	if !seen {
+		return false
+	}
+
+	verbosef("%s has the same content as %s", pageURL, original)
+	c.resultMu.Lock()
+	c.result.Duplicates = append(c.result.Duplicates, Duplicate{URL: pageURL, Original: original, Hash: hash})
+	c.resultMu.Unlock()
+	return true
+}
+
+// canonicalURL returns the absolute, normalized href of the first
+// <link rel="canonical"> in doc, or "".
+func (c *Crawler) canonicalURL(base string, n *html.Node) string {
+	if n.Type == html.ElementNode && n.Data == "link" && attr(n, "href") != "" {
+		for _, rel := range strings.Fields(attr(n, "rel")) {
+			if strings.EqualFold(rel, "canonical") {
+				return normalizeURL(c.formatURL(base, strings.TrimSpace(attr(n, "href"))))
+			}
+		}
+	}
+	for child := n.FirstChild; child != nil; child = child.NextSibling {
+		if u := c.canonicalURL(base, child); u != "" {
+			return u
+		}
+	}
+	return ""
+}
+
+// checkCanonical records that pageURL names canonical as its canonical URL
+// and reports whether some other page has already been crawled as that
+// canonical: the canonical page itself, or an earlier page with the same
+// canonical. A page that is its own canonical is never a duplicate.
+func (c *Crawler) checkCanonical(pageURL, canonical string) bool {
+	c.Mutex.Lock()
+	original, claimed := c.canonicals[canonical]
+	if !claimed {
+		c.canonicals[canonical] = pageURL
+	}
+	visited := c.Visited[canonical]
+	c.Mutex.Unlock()
+
+	switch {
+	case canonical == pageURL:
+		return false
+	case claimed && original != pageURL:
+	case visited:
+		original = canonical
+	default:
+		return false
+	}
+
+	verbosef("%s has canonical URL %s, already crawled as %s", pageURL, canonical, original)
+	c.resultMu.Lock()
+	c.result.Duplicates = append(c.result.Duplicates, Duplicate{URL: pageURL, Original: original, Canonical: true})
+	c.resultMu.Unlock()
+	return true
+}
+
+func writeDuplicates(filename string, duplicates []Duplicate) error {
+	f, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	f.WriteString("--DUPLICATE CONTENT:---\n")
+	for _, d := range duplicates {
+		how := "same content as"
+		if d.Canonical {
+			how = "canonical crawled as"
+		}
+		if _, err := fmt.Fprintf(f, "%s %s %s\n", d.URL, how, d.Original); err != nil {
+			return err
+		}
+	}
+	return nil
+}
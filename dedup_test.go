@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// TestCrawlSkipsSharedCanonical crawls two session variants of one page
+// that name the same rel=canonical. Only the first has its links followed.
+func TestCrawlSkipsSharedCanonical(t *testing.T) {
+	var mu sync.Mutex
+	fetches := make(map[string]int)
+	fetcher := FetcherFunc(func(ctx context.Context, url string) (*http.Response, error) {
+		mu.Lock()
+		fetches[url]++
+		mu.Unlock()
+
+		// Leaves differ so none of them is a content duplicate.
+		status, body := http.StatusOK, "<html><body>leaf "+url+"</body></html>"
+		switch {
+		case url == "http://site.test/":
+			body = `<html><body><a href="/a?session=1">1</a><a href="/a?session=2">2</a></body></html>`
+		case strings.HasPrefix(url, "http://site.test/a"):
+			session := strings.TrimPrefix(url, "http://site.test/a?session=")
+			if session == url {
+				session = "0"
+			}
+			body = fmt.Sprintf(`<html><head><link rel="canonical" href="/a"></head>`+
+				`<body>Welcome, visitor %s<a href="/s%s">only here</a></body></html>`, session, session)
+		case !strings.HasPrefix(url, "http://site.test/s"):
+			status, body = http.StatusNotFound, ""
+		}
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		if err != nil {
+			return nil, err
+		}
+		return &http.Response{
+			Request:    req,
+			StatusCode: status,
+			Header:     http.Header{"Content-Type": {"text/html"}},
+			Body:       io.NopCloser(strings.NewReader(body)),
+		}, nil
+	})
+
+	c := NewCrawler([]string{"site.test"}, nil)
+	c.Fetcher = fetcher
+	c.DedupContent = true
+	res, err := c.Run(context.Background(), []string{"http://site.test/"})
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+
+	if fetches["http://site.test/s1"] != 1 {
+		t.Errorf("link on the first variant fetched %d times, want 1", fetches["http://site.test/s1"])
+	}
+	if fetches["http://site.test/s2"] != 0 {
+		t.Error("links on the second variant were crawled")
+	}
+	if fetches["http://site.test/s0"] != 1 {
+		t.Error("links on the canonical page itself were not crawled")
+	}
+	want := Duplicate{URL: "http://site.test/a?session=2", Original: "http://site.test/a?session=1", Canonical: true}
+	if len(res.Duplicates) != 1 || res.Duplicates[0] != want {
+		t.Errorf("duplicates = %+v, want [%+v]", res.Duplicates, want)
+	}
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"context"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// requestPacer spaces out request starts across all workers. Each request
+// reserves the next slot, delay after the previous one, with jitter of up
+// to ±jitter so the gaps don't form an easily fingerprinted pattern.
+type requestPacer struct {
+	delay, jitter time.Duration
+
+	mu   sync.Mutex
+	next time.Time
+}
+
+// wait blocks until the caller's slot comes up or ctx is done.
+func (p *requestPacer) wait(ctx context.Context) error {
+	gap := p.delay
+	if p.jitter > 0 {
+		gap += time.Duration(rand.Int63n(int64(2*p.jitter)+1)) - p.jitter
+	}
+	gap = max(gap, 0)
+
+	p.mu.Lock()
+	now := time.Now()
+	start := p.next
+	if start.Before(now) {
+		start = now
+	}
+	p.next = start.Add(gap)
+	p.mu.Unlock()
+
+	timer := time.NewTimer(time.Until(start))
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,294 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// resolveFlags collects repeated -resolve host:ip flags.
+type resolveFlags []string
+
+func (r *resolveFlags) String() string {
+	return strings.Join(*r, ", ")
+}
+
+func (r *resolveFlags) Set(value string) error {
+	*r = append(*r, value)
+	return nil
+}
+
+// hostResolver dials by host name using fixed overrides first and then
+// cached DNS lookups, so each host is only resolved once per crawl. The
+// address is rewritten only at dial time: the Host header and TLS SNI
+// still carry the original name.
+type hostResolver struct {
+	dialer   *net.Dialer
+	resolver *net.Resolver
+
+	mu        sync.Mutex
+	overrides map[string]string
+	cache     map[string][]string
+}
+
+func newHostResolver(dialer *net.Dialer) *hostResolver {
+	return &hostResolver{
+		dialer:    dialer,
+		resolver:  net.DefaultResolver,
+		overrides: make(map[string]string),
+		cache:     make(map[string][]string),
+	}
+}
+
+func (r *hostResolver) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+	ips, err := r.lookup(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+
+	primaries, fallbacks := splitByFamily(ips)
+	if len(fallbacks) == 0 {
+		return r.dialSerial(ctx, network, port, primaries)
+	}
+	return r.dialParallel(ctx, network, port, primaries, fallbacks)
+}
+
+// fallbackDelay is how long the first address family gets to connect
+// before the other one is tried alongside it, as net.Dialer does (RFC 6555
+// "Happy Eyeballs"). A host with broken IPv6 then costs 300ms, not a full
+// dial timeout.
+const fallbackDelay = 300 * time.Millisecond
+
+// splitByFamily splits ips into those of the first address's family and
+// the rest, keeping their order.
+func splitByFamily(ips []string) (primaries, fallbacks []string) {
+	isV4 := func(ip string) bool { return net.ParseIP(ip).To4() != nil }
+	for _, ip := range ips {
+		if isV4(ip) == isV4(ips[0]) {
+			primaries = append(primaries, ip)
+		} else {
+			fallbacks = append(fallbacks, ip)
+		}
+	}
+	return primaries, fallbacks
+}
+
+// dialParallel races primaries against fallbacks, which start after
+// fallbackDelay or as soon as the primaries have all failed.
+func (r *hostResolver) dialParallel(ctx context.Context, network, port string, primaries, fallbacks []string) (net.Conn, error) {
+	type dialResult struct {
+		conn    net.Conn
+		err     error
+		primary bool
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	// Buffered so the loser never blocks once the winner has returned.
+	results := make(chan dialResult, 2)
+	dial := func(ips []string, primary bool) {
+		conn, err := r.dialSerial(ctx, network, port, ips)
+		results <- dialResult{conn, err, primary}
+	}
+
+	go dial(primaries, true)
+	timer := time.NewTimer(fallbackDelay)
+	defer timer.Stop()
+	pending, fallbackStarted := 1, false
+	startFallback := func() {
+		if !fallbackStarted {
+			fallbackStarted = true
+			pending++
+			go dial(fallbacks, false)
+		}
+	}
+
+	var primaryErr, fallbackErr error
+	for {
+		select {
+		case <-timer.C:
+			startFallback()
+		case res := <-results:
+			pending--
+			if res.err == nil {
+				if pending > 0 {
+					go func() {
+						if late := <-results; late.conn != nil {
+							late.conn.Close()
+						}
+					}()
+				}
+				return res.conn, nil
+			}
+			if res.primary {
+				primaryErr = res.err
+			} else {
+				fallbackErr = res.err
+			}
+			startFallback()
+			if pending == 0 {
+				if primaryErr != nil {
+					return nil, primaryErr
+				}
+				return nil, fallbackErr
+			}
+		}
+	}
+}
+
+// dialSerial tries ips in order and returns the first connection made.
+func (r *hostResolver) dialSerial(ctx context.Context, network, port string, ips []string) (net.Conn, error) {
+	var firstErr error
+	for _, ip := range ips {
+		conn, err := r.dialer.DialContext(ctx, network, net.JoinHostPort(ip, port))
+		if err == nil {
+			return conn, nil
+		}
+		if firstErr == nil {
+			firstErr = err
+		}
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	return nil, firstErr
+}
+
+func (r *hostResolver) lookup(ctx context.Context, host string) ([]string, error) {
+	if net.ParseIP(host) != nil {
+		return []string{host}, nil
+	}
+	host = strings.ToLower(host)
+
+	r.mu.Lock()
+	if ip, ok := r.overrides[host]; ok {
+		r.mu.Unlock()
+		return []string{ip}, nil
+	}
+	ips, ok := r.cache[host]
+	r.mu.Unlock()
+	if ok {
+		return ips, nil
+	}
+
+	ips, err := r.resolver.LookupHost(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+	if len(ips) == 0 {
+		return nil, fmt.Errorf("no addresses found for %s", host)
+	}
+	r.mu.Lock()
+	r.cache[host] = ips
+	r.mu.Unlock()
+	return ips, nil
+}
+
+// SetResolve makes host resolve to ip, like curl's --resolve.
+func (f *HTTPFetcher) SetResolve(host, ip string) error {
+	if net.ParseIP(ip) == nil {
+		return fmt.Errorf("%q is not an IP address", ip)
+	}
+	f.dns.mu.Lock()
+	f.dns.overrides[strings.ToLower(host)] = ip
+	f.dns.mu.Unlock()
+	return nil
+}
+
+// SetDNSServer sends every lookup to server (host:port) instead of the
+// system resolver.
+func (f *HTTPFetcher) SetDNSServer(server string) error {
+	if _, _, err := net.SplitHostPort(server); err != nil {
+		return err
+	}
+	f.dns.resolver = &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, network, _ string) (net.Conn, error) {
+			return f.dns.dialer.DialContext(ctx, network, server)
+		},
+	}
+	return nil
+}
+
+// HostAddress is one address a discovered host resolved to.
+type HostAddress struct {
+	Host string
+	IP   string
+}
+
+// resolveAll looks up hosts, workers at a time, through the same overrides,
+// cache and DNS server as the crawl. Hosts that don't resolve are logged
+// and left out. The result is sorted by host, then address.
+func (r *hostResolver) resolveAll(ctx context.Context, hosts []string, workers int) []HostAddress {
+	jobs := make(chan string)
+	var (
+		wg    sync.WaitGroup
+		mu    sync.Mutex
+		addrs []HostAddress
+	)
+	for i := 0; i < max(workers, 1); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for host := range jobs {
+				lookupCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+				ips, err := r.lookup(lookupCtx, host)
+				cancel()
+				if err != nil {
+					verbosef("Could not resolve %s: %v", host, err)
+					continue
+				}
+				mu.Lock()
+				for _, ip := range ips {
+					addrs = append(addrs, HostAddress{Host: host, IP: ip})
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	for _, host := range hosts {
+		if ctx.Err() != nil {
+			break
+		}
+		jobs <- host
+	}
+	close(jobs)
+	wg.Wait()
+
+	sort.Slice(addrs, func(i, j int) bool {
+		if addrs[i].Host != addrs[j].Host {
+			return addrs[i].Host < addrs[j].Host
+		}
+		return addrs[i].IP < addrs[j].IP
+	})
+	return addrs
+}
+
+// ResolveHosts resolves hosts with the fetcher's resolver.
+func (f *HTTPFetcher) ResolveHosts(ctx context.Context, hosts []string, workers int) []HostAddress {
+	return f.dns.resolveAll(ctx, hosts, workers)
+}
+
+// writeHostAddresses writes one "host,ip" line per address.
+func writeHostAddresses(filename string, addrs []HostAddress) error {
+	f, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	for _, a := range addrs {
+		if _, err := fmt.Fprintf(f, "%s,%s\n", a.Host, a.IP); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// Email is an address found on URL, either in a mailto: link or in the
+// text of a page or downloaded file.
+type Email struct {
+	Address string `json:"address"`
+	URL     string `json:"url"`
+}
+
+// emailRegex is deliberately conservative. The domain is checked with
+// isHostname afterwards so retina images like logo@2x.png don't count.
+var emailRegex = regexp.MustCompile(`(?i)\b[a-z0-9][a-z0-9._%+\-]{0,63}@(?:[a-z0-9](?:[a-z0-9\-]{0,61}[a-z0-9])?\.)+[a-z]{2,24}\b`)
+
+// obfuscatedEmailRegex matches "user [at] example [dot] com" and the like.
+// Only bracketed forms count; "at" and "dot" as plain words are too common
+// in prose to mean anything.
+var obfuscatedEmailRegex = regexp.MustCompile(`(?i)\b([a-z0-9][a-z0-9._%+\-]{0,63})\s*[\[({<]\s*at\s*[\])}>]\s*((?:[a-z0-9\-]+(?:\.|\s*[\[({<]\s*dot\s*[\])}>]\s*))+[a-z]{2,24})\b`)
+
+var obfuscatedDotRegex = regexp.MustCompile(`(?i)\s*[\[({<]\s*dot\s*[\])}>]\s*`)
+
+// emailAddress lower-cases the domain of an address and checks it, or
+// returns "" if it isn't one.
+func emailAddress(s string) string {
+	local, domain, ok := strings.Cut(s, "@")
+	if !ok || local == "" || !isHostname(domain) {
+		return ""
+	}
+	return local + "@" + strings.ToLower(domain)
+}
+
+// findEmails returns the addresses in text, including obfuscated ones if
+// asked for.
+func findEmails(text string, obfuscated bool) []string {
+	var found []string
+	for _, m := range emailRegex.FindAllString(text, -1) {
+		if addr := emailAddress(m); addr != "" {
+			found = append(found, addr)
+		}
+	}
+	if obfuscated {
+		for _, m := range obfuscatedEmailRegex.FindAllStringSubmatch(text, -1) {
+			domain := obfuscatedDotRegex.ReplaceAllString(m[2], ".")
+			if addr := emailAddress(m[1] + "@" + domain); addr != "" {
+				found = append(found, addr)
+			}
+		}
+	}
+	return found
+}
+
+// mailtoAddresses returns the recipients of a mailto: URL.
+func mailtoAddresses(u string) []string {
+	to, _, _ := strings.Cut(u[len("mailto:"):], "?")
+	if unescaped, err := url.PathUnescape(to); err == nil {
+		to = unescaped
+	}
+	var found []string
+	for _, addr := range strings.Split(to, ",") {
+		if addr = emailAddress(strings.TrimSpace(addr)); addr != "" {
+			found = append(found, addr)
+		}
+	}
+	return found
+}
+
+// htmlText returns the text nodes of an HTML document, one per line, so
+// addresses in attributes and markup aren't matched by accident.
+func htmlText(body []byte) string {
+	var text strings.Builder
+	z := html.NewTokenizer(bytes.NewReader(body))
+	for {
+		switch z.Next() {
+		case html.ErrorToken:
+			return text.String()
+		case html.TextToken:
+			text.Write(z.Text())
+			text.WriteByte('\n')
+		}
+	}
+}
+
+// scanEmails looks for addresses in a downloaded body: the text nodes of
+// an HTML page, or the whole of anything else.
+func (c *Crawler) scanEmails(pageURL, contentType string, body []byte) {
+	if len(body) == 0 {
+		return
+	}
+	text := string(body)
+	if isHTML(contentType, body) {
+		text = htmlText(body)
+	}
+	c.recordEmails(pageURL, findEmails(text, c.ObfuscatedEmails))
+}
+
+func (c *Crawler) recordEmails(pageURL string, addresses []string) {
+	if len(addresses) == 0 {
+		return
+	}
+	c.resultMu.Lock()
+	defer c.resultMu.Unlock()
+	for _, addr := range addresses {
+		key := strings.ToLower(addr)
+		if c.emailsSeen[key] {
+			continue
+		}
+		c.emailsSeen[key] = true
+		verbosef("Email address found on %s: %s", pageURL, addr)
+		c.result.Emails = append(c.result.Emails, Email{Address: addr, URL: pageURL})
+	}
+}
+
+// writeEmails writes each unique address with the first page it was
+// found on.
+func writeEmails(filename string, emails []Email) error {
+	f, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	f.WriteString("--EMAIL ADDRESSES:---\n")
+	for _, e := range emails {
+		if _, err := fmt.Fprintf(f, "%s %s\n", e.Address, e.URL); err != nil {
+			return err
+		}
+	}
+	return nil
+}
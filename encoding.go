@@ -0,0 +1,98 @@
+package main
+
+import (
+	"bufio"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/andybalholm/brotli"
+)
+
+// acceptEncoding is sent explicitly, which switches off the transport's own
+// gzip handling, so decodeBody must cover everything listed here.
+const acceptEncoding = "gzip, deflate, br"
+
+// decodeBody swaps resp.Body for a reader that undoes its Content-Encoding.
+// Stacked encodings such as "gzip, br" are undone last to first. Encodings
+// we can't decode are an error rather than compressed bytes handed to the
+// HTML parser.
+func decodeBody(resp *http.Response) error {
+	encodings := contentEncodings(resp.Header)
+	if len(encodings) == 0 {
+		return nil
+	}
+	r, err := newDecoders(encodings, resp.Body)
+	if err != nil {
+		return err
+	}
+
+	resp.Body = struct {
+		io.Reader
+		io.Closer
+	}{r, resp.Body}
+	resp.Header.Del("Content-Encoding")
+	resp.Header.Del("Content-Length")
+	resp.ContentLength = -1
+	resp.Uncompressed = true
+	return nil
+}
+
+// contentEncodings lists the encodings in h's Content-Encoding, in the
+// order they were applied.
+func contentEncodings(h http.Header) []string {
+	var encodings []string
+	for _, values := range h.Values("Content-Encoding") {
+		for _, e := range strings.Split(values, ",") {
+			if e = strings.ToLower(strings.TrimSpace(e)); e != "" && e != "identity" {
+				encodings = append(encodings, e)
+			}
+		}
+	}
+	return encodings
+}
+
+// newDecoders undoes encodings, last applied first.
+func newDecoders(encodings []string, r io.Reader) (io.Reader, error) {
+	for i := len(encodings) - 1; i >= 0; i-- {
+		var err error
+		if r, err = newDecoder(encodings[i], r); err != nil {
+			return nil, err
+		}
+	}
+	return r, nil
+}
+
+func newDecoder(encoding string, r io.Reader) (io.Reader, error) {
+	switch encoding {
+	case "gzip", "x-gzip":
+		br := bufio.NewReader(r)
+		if _, err := br.Peek(1); err == io.EOF {
+			// Some servers send an empty body with a gzip header.
+			return br, nil
+		}
+		return gzip.NewReader(br)
+	case "deflate":
+		return newDeflateReader(r), nil
+	case "br":
+		return brotli.NewReader(r), nil
+	}
+	return nil, fmt.Errorf("unsupported Content-Encoding %q", encoding)
+}
+
+// newDeflateReader handles both zlib wrapped deflate, which is what the spec
+// asks for, and the raw deflate streams a lot of servers send instead.
+func newDeflateReader(r io.Reader) io.Reader {
+	br := bufio.NewReader(r)
+	header, err := br.Peek(2)
+	if err == nil && header[0]&0x0f == 8 && (uint16(header[0])<<8|uint16(header[1]))%31 == 0 {
+		if zr, err := zlib.NewReader(br); err == nil {
+			return zr
+		}
+	}
+	return flate.NewReader(br)
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+// crawlTestServer crawls a test server running handler from its root.
+func crawlTestServer(t *testing.T, handler http.Handler) (*Result, string) {
+	t.Helper()
+	srv := httptest.NewServer(handler)
+	t.Cleanup(srv.Close)
+	u, _ := url.Parse(srv.URL)
+	c := NewCrawler([]string{u.Host}, nil)
+	res, err := c.Run(context.Background(), []string{srv.URL + "/"})
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	return res, srv.URL
+}
+
+// hasInScope reports whether res lists u as in scope.
+func hasInScope(res *Result, u string) bool {
+	for _, d := range res.InScope {
+		if d.URL == u {
+			return true
+		}
+	}
+	return false
+}
+
+func TestCrawlLatin1Page(t *testing.T) {
+	// "café" and "Ünïcödé" in ISO-8859-1: one byte per accented letter.
+	const body = "<html><head>%s</head><body>" +
+		"<a href=\"/caf\xe9/men\xfc\">menu</a>" +
+		"<a href=\"/\xdcn\xefc\xf6d\xe9?q=cr\xe8me\">search</a>" +
+		"</body></html>"
+	tests := []struct {
+		name        string
+		contentType string
+		meta        string
+	}{
+		{"header", "text/html; charset=ISO-8859-1", ""},
+		{"meta", "text/html", `<meta charset="iso-8859-1">`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res, base := crawlTestServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path != "/" {
+					http.NotFound(w, r)
+					return
+				}
+				w.Header().Set("Content-Type", tt.contentType)
+				fmt.Fprintf(w, body, tt.meta)
+			}))
+			for _, want := range []string{
+				base + "/caf%C3%A9/men%C3%BC",
+				// url.URL leaves the query as it was written, now UTF-8.
+				base + "/%C3%9Cn%C3%AFc%C3%B6d%C3%A9?q=crème",
+			} {
+				if !hasInScope(res, want) {
+					t.Errorf("%s not found; in scope: %v", want, res.InScope)
+				}
+			}
+		})
+	}
+}
+
+func TestCrawlCompressedPage(t *testing.T) {
+	const page = `<html><body><a href="/from-compressed">next</a></body></html>`
+	compress := map[string]func(io.Writer) io.WriteCloser{
+		"gzip":    func(w io.Writer) io.WriteCloser { return gzip.NewWriter(w) },
+		"deflate": func(w io.Writer) io.WriteCloser { zw, _ := flate.NewWriter(w, flate.DefaultCompression); return zw },
+	}
+	for encoding, newWriter := range compress {
+		t.Run(encoding, func(t *testing.T) {
+			var buf bytes.Buffer
+			zw := newWriter(&buf)
+			zw.Write([]byte(page))
+			zw.Close()
+
+			res, base := crawlTestServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path != "/" {
+					http.NotFound(w, r)
+					return
+				}
+				w.Header().Set("Content-Type", "text/html")
+				w.Header().Set("Content-Encoding", encoding)
+				w.Write(buf.Bytes())
+			}))
+			if !hasInScope(res, base+"/from-compressed") {
+				t.Errorf("link in %s page not found; in scope: %v", encoding, res.InScope)
+			}
+		})
+	}
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"bytes"
+	"encoding/xml"
+	"strings"
+
+	"golang.org/x/net/html/charset"
+)
+
+// viaFeed tags URLs found in an RSS or Atom feed.
+const viaFeed = "feed"
+
+// feedDoc covers RSS 2.0 (<rss><channel>), RSS 1.0 (<rdf:RDF> with items
+// at the top level) and Atom (<feed><entry>). feedLink matches both the
+// RSS <link>URL</link> and the Atom <link href="URL"/> forms, whatever
+// namespace they are in.
+type feedDoc struct {
+	XMLName xml.Name
+	Channel feedChannel `xml:"channel"`
+	Items   []feedItem  `xml:"item"`
+	Links   []feedLink  `xml:"link"`
+	Entries []feedItem  `xml:"entry"`
+}
+
+type feedChannel struct {
+	Links []feedLink `xml:"link"`
+	Items []feedItem `xml:"item"`
+}
+
+type feedItem struct {
+	Links      []feedLink `xml:"link"`
+	GUID       feedGUID   `xml:"guid"`
+	Comments   string     `xml:"comments"`
+	Enclosures []struct {
+		URL string `xml:"url,attr"`
+	} `xml:"enclosure"`
+}
+
+type feedLink struct {
+	Href string `xml:"href,attr"`
+	Text string `xml:",chardata"`
+}
+
+type feedGUID struct {
+	IsPermaLink string `xml:"isPermaLink,attr"`
+	Text        string `xml:",chardata"`
+}
+
+func newFeedDecoder(body []byte) *xml.Decoder {
+	dec := xml.NewDecoder(bytes.NewReader(body))
+	dec.Strict = false
+	dec.CharsetReader = charset.NewReaderLabel
+	return dec
+}
+
+// isFeed reports whether body's root element is <rss>, <feed> or <rdf:RDF>.
+// Only the first element is decoded, so HTML pages are rejected cheaply.
+func isFeed(body []byte) bool {
+	dec := newFeedDecoder(body)
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			return false
+		}
+		if se, ok := tok.(xml.StartElement); ok {
+			switch se.Name.Local {
+			case "rss", "feed", "RDF":
+				return true
+			}
+			return false
+		}
+	}
+}
+
+// feedLinks returns every link, item URL, permalink GUID and enclosure in
+// an RSS or Atom feed, unresolved.
+func feedLinks(body []byte) ([]string, error) {
+	var doc feedDoc
+	if err := newFeedDecoder(body).Decode(&doc); err != nil {
+		return nil, err
+	}
+
+	var links []string
+	add := func(ls []feedLink) {
+		for _, l := range ls {
+			if u := strings.TrimSpace(l.Href + l.Text); u != "" {
+				links = append(links, u)
+			}
+		}
+	}
+	add(doc.Links)
+	add(doc.Channel.Links)
+	for _, items := range [][]feedItem{doc.Items, doc.Channel.Items, doc.Entries} {
+		for _, item := range items {
+			add(item.Links)
+			// A GUID is a permalink unless it says otherwise, but plenty
+			// of feeds use bare ids without saying so.
+			guid := strings.TrimSpace(item.GUID.Text)
+			if strings.Contains(guid, "://") && !strings.EqualFold(item.GUID.IsPermaLink, "false") {
+				links = append(links, guid)
+			}
+			if c := strings.TrimSpace(item.Comments); c != "" {
+				links = append(links, c)
+			}
+			for _, e := range item.Enclosures {
+				if e.URL != "" {
+					links = append(links, e.URL)
+				}
+			}
+		}
+	}
+	return links, nil
+}
@@ -0,0 +1,305 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"time"
+
+	"golang.org/x/net/publicsuffix"
+)
+
+// Without timeouts a single server that never answers stalls the crawl, so
+// every request gets an overall deadline and connections their own limits.
+const (
+	defaultRequestTimeout = 15 * time.Second
+	dialTimeout           = 10 * time.Second
+	tlsHandshakeTimeout   = 10 * time.Second
+)
+
+const defaultUserAgent = "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/58.0.3029.110 Safari/537.3"
+
+// Fetcher retrieves a single URL for the crawler. Swap it out on Crawler to
+// crawl without touching the network.
+type Fetcher interface {
+	Fetch(ctx context.Context, url string) (*http.Response, error)
+}
+
+// FetcherFunc adapts a plain function to the Fetcher interface, which is
+// usually all a test needs.
+type FetcherFunc func(ctx context.Context, url string) (*http.Response, error)
+
+func (f FetcherFunc) Fetch(ctx context.Context, url string) (*http.Response, error) {
+	return f(ctx, url)
+}
+
+// HeadFetcher is implemented by fetchers that can ask for a URL's headers
+// without its body. Crawler.HeadFirst needs one.
+type HeadFetcher interface {
+	Head(ctx context.Context, url string) (*http.Response, error)
+}
+
+type HTTPFetcher struct {
+	Client *http.Client
+
+	// Transport is the connection level transport under Client. Client's
+	// own Transport may wrap it, e.g. to record a HAR, so proxy and TLS
+	// settings go here.
+	Transport *http.Transport
+
+	// UserAgent is sent with every request. When UserAgents is non-empty
+	// one of those is picked at random per request instead.
+	UserAgent  string
+	UserAgents []string
+
+	// Header, Authorization and any cookies in the jar are only sent to
+	// URLs InScope accepts, so credentials never reach third-party hosts.
+	// A nil InScope sends them everywhere.
+	Header        http.Header
+	Authorization string
+	InScope       func(url string) bool
+
+	// MaxRedirects limits how many redirects one fetch follows. With
+	// NoFollowRedirects, or a MaxRedirects of zero, the 3xx response itself
+	// is returned instead.
+	MaxRedirects      int
+	NoFollowRedirects bool
+
+	// NoReferer stops the Referer header set through WithReferer from
+	// being sent.
+	NoReferer bool
+
+	dns *hostResolver
+
+	// proxy and proxyInsecure are what SetProxy was given, kept so the
+	// Chrome pass can be sent the same way.
+	proxy         *url.URL
+	proxyInsecure bool
+}
+
+type refererKey struct{}
+
+// WithReferer returns a context under which HTTPFetcher sends referer as
+// the Referer header, like a browser following a link from that page. As
+// with other credentials it only goes to in-scope URLs.
+func WithReferer(ctx context.Context, referer string) context.Context {
+	return context.WithValue(ctx, refererKey{}, referer)
+}
+
+// scopedJar only hands out cookies for URLs allow accepts. Redirects pick up
+// cookies after CheckRedirect runs, so the jar is the one place that sees
+// every hop.
+type scopedJar struct {
+	http.CookieJar
+	allow func(url string) bool
+}
+
+func (j *scopedJar) Cookies(u *url.URL) []*http.Cookie {
+	if !j.allow(u.String()) {
+		return nil
+	}
+	return j.CookieJar.Cookies(u)
+}
+
+func NewHTTPFetcher() *HTTPFetcher {
+	// Every request shares the jar so Set-Cookie responses carry over to
+	// later requests, which authenticated crawls depend on.
+	jar, _ := cookiejar.New(&cookiejar.Options{PublicSuffixList: publicsuffix.List})
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	dns := newHostResolver(&net.Dialer{Timeout: dialTimeout, KeepAlive: 30 * time.Second})
+	transport.DialContext = dns.DialContext
+	transport.TLSHandshakeTimeout = tlsHandshakeTimeout
+	f := &HTTPFetcher{
+		Client:       &http.Client{Transport: transport, Timeout: defaultRequestTimeout},
+		Transport:    transport,
+		UserAgent:    defaultUserAgent,
+		MaxRedirects: defaultMaxRedirects,
+		dns:          dns,
+	}
+	f.Client.Jar = &scopedJar{CookieJar: jar, allow: f.sendCredentials}
+	return f
+}
+
+// Fetch gets pageURL, retrying once with the other scheme, and hands back a
+// response whose body has already had any Content-Encoding removed.
+func (f *HTTPFetcher) Fetch(ctx context.Context, pageURL string) (*http.Response, error) {
+	resp, err := f.fetch(ctx, pageURL)
+	if err != nil {
+		return resp, err
+	}
+	if err := decodeBody(resp); err != nil {
+		resp.Body.Close()
+		return nil, fmt.Errorf("decoding %s response: %w", resp.Header.Get("Content-Encoding"), err)
+	}
+	return resp, nil
+}
+
+// Head sends a HEAD request for pageURL. Redirects are not followed; the
+// caller is expected to fall back to a GET for anything but a 2xx.
+func (f *HTTPFetcher) Head(ctx context.Context, pageURL string) (*http.Response, error) {
+	req, err := f.newRequest(ctx, "HEAD", pageURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	client := *f.Client
+	client.CheckRedirect = func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+	return resp, nil
+}
+
+func (f *HTTPFetcher) userAgent() string {
+	if len(f.UserAgents) > 0 {
+		return f.UserAgents[rand.Intn(len(f.UserAgents))]
+	}
+	return f.UserAgent
+}
+
+func (f *HTTPFetcher) sendCredentials(u string) bool {
+	return f.InScope == nil || f.InScope(u)
+}
+
+// SetHTTPVersion restricts the transport to HTTP/1.1 (1) or HTTP/2 (2).
+// Any other version leaves the usual negotiation in place.
+func (f *HTTPFetcher) SetHTTPVersion(version int) {
+	var protocols http.Protocols
+	switch version {
+	case 1:
+		protocols.SetHTTP1(true)
+	case 2:
+		protocols.SetHTTP2(true)
+	default:
+		return
+	}
+	f.Transport.Protocols = &protocols
+}
+
+// newRequest builds a request for u carrying the user agent and, for
+// in-scope URLs, the configured credentials and extra headers.
+func (f *HTTPFetcher) newRequest(ctx context.Context, method, u string, body io.Reader) (*http.Request, error) {
+	req, err := f.newBareRequest(ctx, method, u, body)
+	if err != nil {
+		return nil, err
+	}
+	if f.sendCredentials(u) {
+		f.addCredentials(ctx, req)
+	}
+	return req, nil
+}
+
+// newBareRequest builds a request for u carrying nothing but the user
+// agent and Accept-Encoding.
+func (f *HTTPFetcher) newBareRequest(ctx context.Context, method, u string, body io.Reader) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, method, u, body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", f.userAgent())
+	req.Header.Set("Accept-Encoding", acceptEncoding)
+	return req, nil
+}
+
+// addCredentials sets Authorization, the Referer and the extra headers.
+func (f *HTTPFetcher) addCredentials(ctx context.Context, req *http.Request) {
+	if f.Authorization != "" {
+		req.Header.Set("Authorization", f.Authorization)
+	}
+	if referer, _ := ctx.Value(refererKey{}).(string); referer != "" && !f.NoReferer {
+		req.Header.Set("Referer", referer)
+	}
+	for name, values := range f.Header {
+		req.Header.Del(name)
+		for _, v := range values {
+			req.Header.Add(name, v)
+		}
+	}
+}
+
+func (f *HTTPFetcher) fetch(ctx context.Context, pageURL string) (*http.Response, error) {
+	var redirectURL string
+	client := *f.Client
+	client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		if f.NoFollowRedirects || f.MaxRedirects <= 0 {
+			return http.ErrUseLastResponse
+		}
+		if len(via) >= f.MaxRedirects {
+			return fmt.Errorf("stopped after %d redirects", f.MaxRedirects)
+		}
+		redirectURL = req.URL.String()
+		// net/http keeps Authorization on redirects to subdomains, which
+		// may well be out of scope.
+		if !f.sendCredentials(redirectURL) {
+			req.Header.Del("Authorization")
+			for name := range f.Header {
+				req.Header.Del(name)
+			}
+		}
+		verbosef("Redirected from %s to %s", via[len(via)-1].URL, redirectURL)
+		return nil
+	}
+
+	req, err := f.newRequest(ctx, "GET", pageURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("creating request: %w", err)
+	}
+	resp, err := client.Do(req)
+	// Any response, whatever its status, is the answer for this URL.
+	if err == nil {
+		return resp, nil
+	}
+	// The caller logs the error, so it says where a redirect led.
+	err = certHint(err)
+	if redirectURL != "" {
+		err = fmt.Errorf("%w (redirected to %s)", err, redirectURL)
+	}
+	// A failed redirect hands back the last response with its body
+	// already closed; it was reached, so there's nothing to retry.
+	if resp != nil || ctx.Err() != nil {
+		return nil, err
+	}
+	// A TLS failure is exactly what a man in the middle looks like, so it
+	// is never an excuse to try again in cleartext.
+	if req.URL.Scheme == "https" && isTLSError(err) {
+		return nil, err
+	}
+
+	// The connection itself failed, so the site may only be served on
+	// the other scheme.
+	u, _ := url.Parse(pageURL)
+	switch u.Scheme {
+	case "http":
+		u.Scheme = "https"
+	case "https":
+		u.Scheme = "http"
+	default:
+		return nil, err
+	}
+	// The client adds jar cookies to the request it is given, so the retry
+	// needs a fresh one or it would carry the first attempt's cookies too.
+	if u.Scheme == "http" {
+		// Credentials meant for https never go out in cleartext, so the
+		// downgraded request carries no headers of ours and no cookies.
+		req, err = f.newBareRequest(ctx, "GET", u.String(), nil)
+		client.Jar = nil
+	} else {
+		req, err = f.newRequest(ctx, "GET", u.String(), nil)
+	}
+	if err != nil {
+		return nil, err
+	}
+	resp, err = client.Do(req)
+	if err != nil {
+		return nil, certHint(err)
+	}
+	return resp, nil
+}
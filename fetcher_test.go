@@ -0,0 +1,199 @@
+package main
+
+import (
+	"context"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+// countingListener counts the connections a test server accepts.
+type countingListener struct {
+	net.Listener
+	conns atomic.Int32
+}
+
+func (l *countingListener) Accept() (net.Conn, error) {
+	c, err := l.Listener.Accept()
+	if err == nil {
+		l.conns.Add(1)
+	}
+	return c, err
+}
+
+// newCountingServer starts a test server on a counting listener.
+func newCountingServer(t *testing.T, handler http.Handler, tls bool) (*httptest.Server, *countingListener) {
+	t.Helper()
+	srv := httptest.NewUnstartedServer(handler)
+	srv.Config.ErrorLog = log.New(io.Discard, "", 0)
+	ln := &countingListener{Listener: srv.Listener}
+	srv.Listener = ln
+	if tls {
+		srv.StartTLS()
+	} else {
+		srv.Start()
+	}
+	t.Cleanup(srv.Close)
+	return srv, ln
+}
+
+func TestFetchNon200ReusesConnection(t *testing.T) {
+	var requests atomic.Int32
+	srv, ln := newCountingServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		http.NotFound(w, r)
+	}), false)
+
+	f := NewHTTPFetcher()
+	for i := 0; i < 5; i++ {
+		resp, err := f.Fetch(context.Background(), srv.URL+"/missing")
+		if err != nil {
+			t.Fatalf("fetch %d: %v", i, err)
+		}
+		if resp.StatusCode != http.StatusNotFound {
+			t.Fatalf("fetch %d: status %d, want 404", i, resp.StatusCode)
+		}
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+	}
+	// A 404 is an answer: no retry on the other scheme, and the one
+	// connection is reused because no body was left open.
+	if n := requests.Load(); n != 5 {
+		t.Errorf("server saw %d requests, want 5", n)
+	}
+	if n := ln.conns.Load(); n != 1 {
+		t.Errorf("server accepted %d connections, want 1", n)
+	}
+}
+
+func TestFetchZeroMaxRedirectsReturnsRedirect(t *testing.T) {
+	srv, _ := newCountingServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/old" {
+			http.Redirect(w, r, "/new", http.StatusFound)
+		}
+	}), false)
+
+	f := NewHTTPFetcher()
+	f.MaxRedirects = 0
+	resp, err := f.Fetch(context.Background(), srv.URL+"/old")
+	if err != nil {
+		t.Fatalf("fetch: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusFound || resp.Header.Get("Location") != "/new" {
+		t.Errorf("got %d to %q, want the 302 to /new", resp.StatusCode, resp.Header.Get("Location"))
+	}
+}
+
+func TestFetchNoCleartextRetryAfterCertError(t *testing.T) {
+	srv, ln := newCountingServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("request reached the server: %s %s", r.Method, r.URL)
+	}), true)
+
+	f := NewHTTPFetcher()
+	f.Authorization = "Bearer secret"
+	_, err := f.Fetch(context.Background(), srv.URL+"/")
+	if err == nil {
+		t.Fatal("fetch with an untrusted certificate succeeded")
+	}
+	if !strings.Contains(err.Error(), "-insecure") {
+		t.Errorf("error %q does not suggest -insecure", err)
+	}
+	if n := ln.conns.Load(); n != 1 {
+		t.Errorf("server accepted %d connections, want 1 (no retry over http)", n)
+	}
+}
+
+func TestFetchDowngradeDropsCredentials(t *testing.T) {
+	var got http.Header
+	srv, _ := newCountingServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header.Clone()
+	}), false)
+
+	// https://site.test refuses connections; http://site.test is srv.
+	f := NewHTTPFetcher()
+	f.Transport.DialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+		if addr != "site.test:80" {
+			return nil, &net.OpError{Op: "dial", Net: network, Err: io.EOF}
+		}
+		var d net.Dialer
+		return d.DialContext(ctx, network, srv.Listener.Addr().String())
+	}
+	f.Authorization = "Bearer secret"
+	f.Header = http.Header{"X-Api-Key": {"key"}}
+	u := "https://site.test/"
+	resp, err := f.Fetch(WithReferer(context.Background(), "https://site.test/from"), u)
+	if err != nil {
+		t.Fatalf("fetch: %v", err)
+	}
+	resp.Body.Close()
+	if resp.Request.URL.Scheme != "http" {
+		t.Fatalf("fetched %s, want the http fallback", resp.Request.URL)
+	}
+	for _, name := range []string{"Authorization", "X-Api-Key", "Referer", "Cookie"} {
+		if v := got.Get(name); v != "" {
+			t.Errorf("downgraded request sent %s: %q", name, v)
+		}
+	}
+}
+
+func TestFetchThirdPartyGetsNoCredentials(t *testing.T) {
+	// Both servers are on 127.0.0.1, so without the scope check the jar
+	// would hand the first server's cookie to the second as well.
+	var third http.Header
+	thirdParty, _ := newCountingServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		third = r.Header.Clone()
+	}), false)
+	var own http.Header
+	site, _ := newCountingServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		own = r.Header.Clone()
+		if r.URL.Path == "/out" {
+			http.Redirect(w, r, thirdParty.URL+"/redirected", http.StatusFound)
+		}
+	}), false)
+
+	f := NewHTTPFetcher()
+	f.InScope = func(u string) bool { return strings.HasPrefix(u, site.URL+"/") }
+	f.Authorization = "Bearer secret"
+	f.Header = http.Header{"X-Api-Key": {"key"}}
+	siteURL, _ := url.Parse(site.URL + "/")
+	f.Client.Jar.SetCookies(siteURL, []*http.Cookie{{Name: "session", Value: "abc"}})
+
+	for _, path := range []string{"/", "/out"} {
+		resp, err := f.Fetch(context.Background(), site.URL+path)
+		if err != nil {
+			t.Fatalf("fetch %s: %v", path, err)
+		}
+		resp.Body.Close()
+	}
+	if own.Get("Authorization") == "" || own.Get("X-Api-Key") == "" || own.Get("Cookie") == "" {
+		t.Errorf("in-scope request missing credentials: %v", own)
+	}
+	if third == nil {
+		t.Fatal("redirect to the third party was not followed")
+	}
+
+	check := func(how string) {
+		t.Helper()
+		for _, name := range []string{"Authorization", "X-Api-Key", "Cookie"} {
+			if v := third.Get(name); v != "" {
+				t.Errorf("%s third-party request sent %s: %q", how, name, v)
+			}
+		}
+	}
+	check("redirected")
+
+	third = nil
+	resp, err := f.Fetch(context.Background(), thirdParty.URL+"/direct")
+	if err != nil {
+		t.Fatalf("fetch third party: %v", err)
+	}
+	resp.Body.Close()
+	check("direct")
+}
@@ -0,0 +1,250 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// viaForm tags the URLs -crawl-get-forms makes up from GET forms.
+const viaForm = "form"
+
+// Form is an HTML form found on Page, with its action resolved against the
+// page URL.
+type Form struct {
+	Page   string      `json:"page"`
+	Action string      `json:"action"`
+	Method string      `json:"method"`
+	Fields []FormField `json:"fields"`
+}
+
+// FormField is a named input, select or textarea inside a form, or a
+// submit button. Value is the default a browser would submit. Buttons with
+// formaction or formmethod submit the form somewhere else, given by
+// FormAction and FormMethod.
+type FormField struct {
+	Name       string `json:"name"`
+	Type       string `json:"type"`
+	Value      string `json:"value,omitempty"`
+	Checked    bool   `json:"checked,omitempty"`
+	FormAction string `json:"formaction,omitempty"`
+	FormMethod string `json:"formmethod,omitempty"`
+}
+
+func (c *Crawler) extractForms(base string, n *html.Node) []Form {
+	var forms []Form
+	if n.Type == html.ElementNode && n.Data == "form" {
+		form := Form{Page: base, Action: base, Method: "GET", Fields: []FormField{}}
+		for _, a := range n.Attr {
+			switch a.Key {
+			case "action":
+				if strings.TrimSpace(a.Val) != "" {
+					form.Action = c.formatURL(base, a.Val)
+				}
+			case "method":
+				form.Method = strings.ToUpper(a.Val)
+			}
+		}
+		form.Fields = c.formFields(base, n, form.Fields)
+		forms = append(forms, form)
+	}
+
+	for child := n.FirstChild; child != nil; child = child.NextSibling {
+		forms = append(forms, c.extractForms(base, child)...)
+	}
+	return forms
+}
+
+// formFields collects the fields under n, however deeply nested in
+// fieldsets, divs or tables.
+func (c *Crawler) formFields(base string, n *html.Node, fields []FormField) []FormField {
+	for child := n.FirstChild; child != nil; child = child.NextSibling {
+		if child.Type == html.ElementNode {
+			switch child.Data {
+			case "input", "select", "textarea", "button":
+				field := FormField{Name: attr(child, "name"), Type: child.Data}
+				switch child.Data {
+				case "input":
+					field.Type = strings.ToLower(attr(child, "type"))
+					if field.Type == "" {
+						field.Type = "text"
+					}
+					field.Value = attr(child, "value")
+					field.Checked = hasAttr(child, "checked")
+				case "button":
+					field.Type = strings.ToLower(attr(child, "type"))
+					if field.Type == "" {
+						field.Type = "submit"
+					}
+					field.Value = attr(child, "value")
+				case "select":
+					field.Value = selectedOption(child)
+				case "textarea":
+					field.Value = textContent(child)
+				}
+				if v := strings.TrimSpace(attr(child, "formaction")); v != "" {
+					field.FormAction = c.formatURL(base, v)
+				}
+				field.FormMethod = strings.ToUpper(attr(child, "formmethod"))
+				// Unnamed fields aren't submitted, but a button that sends
+				// the form elsewhere is worth knowing about anyway.
+				if field.Name != "" || field.FormAction != "" || field.FormMethod != "" {
+					fields = append(fields, field)
+				}
+			}
+		}
+		fields = c.formFields(base, child, fields)
+	}
+	return fields
+}
+
+// selectedOption returns the value a <select> submits by default: its
+// selected option, or else its first one.
+func selectedOption(n *html.Node) string {
+	var first, selected *html.Node
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		for child := n.FirstChild; child != nil; child = child.NextSibling {
+			if child.Type == html.ElementNode && child.Data == "option" {
+				if first == nil {
+					first = child
+				}
+				if selected == nil && hasAttr(child, "selected") {
+					selected = child
+				}
+			}
+			walk(child)
+		}
+	}
+	walk(n)
+	if selected == nil {
+		selected = first
+	}
+	if selected == nil {
+		return ""
+	}
+	for _, a := range selected.Attr {
+		if a.Key == "value" {
+			return a.Val
+		}
+	}
+	return strings.TrimSpace(textContent(selected))
+}
+
+func textContent(n *html.Node) string {
+	var b strings.Builder
+	for child := n.FirstChild; child != nil; child = child.NextSibling {
+		if child.Type == html.TextNode {
+			b.WriteString(child.Data)
+		} else {
+			b.WriteString(textContent(child))
+		}
+	}
+	return b.String()
+}
+
+// QueryURL returns the URL a browser loads when a GET form is submitted
+// without changes, or "" for other methods. Unchecked boxes, file inputs
+// and buttons are left out, as a browser would.
+func (f Form) QueryURL() string {
+	if f.Method != "GET" && f.Method != "" {
+		return ""
+	}
+	u, err := url.Parse(f.Action)
+	if err != nil {
+		return ""
+	}
+	query := url.Values{}
+	for _, field := range f.Fields {
+		switch field.Type {
+		case "submit", "button", "reset", "image", "file":
+			continue
+		case "checkbox", "radio":
+			if !field.Checked {
+				continue
+			}
+			if field.Value == "" {
+				field.Value = "on"
+			}
+		}
+		if field.Name != "" {
+			query.Add(field.Name, field.Value)
+		}
+	}
+	// A GET submission replaces the action's query string entirely.
+	u.RawQuery = query.Encode()
+	u.Fragment = ""
+	return u.String()
+}
+
+func hasAttr(n *html.Node, key string) bool {
+	for _, a := range n.Attr {
+		if a.Key == key {
+			return true
+		}
+	}
+	return false
+}
+
+func attr(n *html.Node, key string) string {
+	for _, a := range n.Attr {
+		if a.Key == key {
+			return a.Val
+		}
+	}
+	return ""
+}
+
+// writeForms lists every form as its method and action followed by one
+// indented line per field.
+func writeForms(filename string, forms []Form) error {
+	f, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	f.WriteString("--FORMS:---\n")
+	for _, form := range forms {
+		if _, err := fmt.Fprintf(f, "%s %s (on %s)\n", form.Method, form.Action, form.Page); err != nil {
+			return err
+		}
+		for _, field := range form.Fields {
+			line := "  " + field.Type
+			if field.Name != "" {
+				line += " " + field.Name
+			}
+			if field.Value != "" {
+				line += "=" + field.Value
+			}
+			if field.Checked {
+				line += " (checked)"
+			}
+			if field.FormAction != "" || field.FormMethod != "" {
+				line += " ->"
+				for _, s := range []string{field.FormMethod, field.FormAction} {
+					if s != "" {
+						line += " " + s
+					}
+				}
+			}
+			fmt.Fprintln(f, line)
+		}
+	}
+	return nil
+}
+
+func writeFormsJSON(filename string, forms []Form) error {
+	if forms == nil {
+		forms = []Form{}
+	}
+	data, err := json.MarshalIndent(forms, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filename, data, 0644)
+}
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// LinkGraph is the parent -> child link structure of a crawl. Each URL is
+// stored once in Nodes and edges refer to nodes by index, which keeps big
+// crawls from holding every URL string many times over. It is not safe for
+// concurrent use; the crawler guards it with its result lock.
+type LinkGraph struct {
+	Nodes []GraphNode
+	Edges []GraphEdge
+
+	index map[string]int32
+	edges map[GraphEdge]bool
+}
+
+type GraphNode struct {
+	URL     string
+	InScope bool
+	Status  int
+}
+
+type GraphEdge struct {
+	From int32
+	To   int32
+}
+
+func NewLinkGraph() *LinkGraph {
+	return &LinkGraph{
+		index: make(map[string]int32),
+		edges: make(map[GraphEdge]bool),
+	}
+}
+
+func (g *LinkGraph) node(u string, inScope bool) int32 {
+	if id, ok := g.index[u]; ok {
+		return id
+	}
+	id := int32(len(g.Nodes))
+	g.Nodes = append(g.Nodes, GraphNode{URL: u, InScope: inScope})
+	g.index[u] = id
+	return id
+}
+
+// AddLink records that target was found on source. Repeated links are
+// only stored once.
+func (g *LinkGraph) AddLink(source, target string, sourceInScope, targetInScope bool) {
+	e := GraphEdge{g.node(source, sourceInScope), g.node(target, targetInScope)}
+	if !g.edges[e] {
+		g.edges[e] = true
+		g.Edges = append(g.Edges, e)
+	}
+}
+
+func (g *LinkGraph) SetStatus(u string, inScope bool, status int) {
+	g.Nodes[g.node(u, inScope)].Status = status
+}
+
+// Limit returns a copy holding only the first maxNodes nodes, in discovery
+// order, and the edges between them. maxNodes <= 0 means no limit.
+func (g *LinkGraph) Limit(maxNodes int) *LinkGraph {
+	if maxNodes <= 0 || len(g.Nodes) <= maxNodes {
+		return g
+	}
+	out := NewLinkGraph()
+	out.Nodes = g.Nodes[:maxNodes]
+	for _, e := range g.Edges {
+		if int(e.From) < maxNodes && int(e.To) < maxNodes {
+			out.Edges = append(out.Edges, e)
+		}
+	}
+	return out
+}
+
+// ByHost collapses every URL into a node for its host. A host is in scope
+// if any of its URLs were, and self links are dropped. Status is not kept
+// since it means nothing for a whole host.
+func (g *LinkGraph) ByHost() *LinkGraph {
+	out := NewLinkGraph()
+	hosts := make([]int32, len(g.Nodes))
+	for i, n := range g.Nodes {
+		host := n.URL
+		if u, err := url.Parse(n.URL); err == nil && u.Host != "" {
+			host = u.Host
+		}
+		hosts[i] = out.node(host, n.InScope)
+		if n.InScope {
+			out.Nodes[hosts[i]].InScope = true
+		}
+	}
+	for _, e := range g.Edges {
+		he := GraphEdge{hosts[e.From], hosts[e.To]}
+		if he.From != he.To && !out.edges[he] {
+			out.edges[he] = true
+			out.Edges = append(out.Edges, he)
+		}
+	}
+	return out
+}
+
+// writeDOT writes the graph as a Graphviz digraph. In-scope nodes are boxes,
+// out-of-scope ones grey ellipses, and fetched nodes are filled by status.
+func writeDOT(filename string, g *LinkGraph) error {
+	f, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	w := bufio.NewWriter(f)
+	fmt.Fprintln(w, "digraph crawl {")
+	fmt.Fprintln(w, "  node [style=filled, fillcolor=white];")
+	for i, n := range g.Nodes {
+		shape, color := "box", "black"
+		if !n.InScope {
+			shape, color = "ellipse", "grey"
+		}
+		fmt.Fprintf(w, "  n%d [label=%s, shape=%s, color=%s, fillcolor=%s];\n",
+			i, dotQuote(n.URL), shape, color, statusColor(n.Status))
+	}
+	for _, e := range g.Edges {
+		fmt.Fprintf(w, "  n%d -> n%d;\n", e.From, e.To)
+	}
+	fmt.Fprintln(w, "}")
+	return w.Flush()
+}
+
+type graphJSON struct {
+	Nodes []graphJSONNode `json:"nodes"`
+	Edges []graphJSONEdge `json:"edges"`
+}
+
+type graphJSONNode struct {
+	ID     int    `json:"id"`
+	URL    string `json:"url"`
+	Scope  string `json:"scope"`
+	Status int    `json:"status,omitempty"`
+}
+
+type graphJSONEdge struct {
+	Source int32 `json:"source"`
+	Target int32 `json:"target"`
+}
+
+func writeGraphJSON(filename string, g *LinkGraph) error {
+	doc := graphJSON{
+		Nodes: make([]graphJSONNode, len(g.Nodes)),
+		Edges: make([]graphJSONEdge, len(g.Edges)),
+	}
+	for i, n := range g.Nodes {
+		doc.Nodes[i] = graphJSONNode{ID: i, URL: n.URL, Scope: scopeName(n.InScope), Status: n.Status}
+	}
+	for i, e := range g.Edges {
+		doc.Edges[i] = graphJSONEdge{e.From, e.To}
+	}
+
+	data, err := json.Marshal(doc)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filename, data, 0644)
+}
+
+func statusColor(status int) string {
+	switch {
+	case status == 0:
+		return "white"
+	case status < 300:
+		return "palegreen"
+	case status < 400:
+		return "lightblue"
+	case status < 500:
+		return "orange"
+	}
+	return "tomato"
+}
+
+func dotQuote(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	s = strings.ReplaceAll(s, `"`, `\"`)
+	return `"` + s + `"`
+}
@@ -0,0 +1,318 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// harBodyThreshold is the largest response body embedded in the HAR when
+// HARRecorder.AllBodies is not set.
+const harBodyThreshold = 64 << 10
+
+type harLog struct {
+	Log harContent `json:"log"`
+}
+
+type harContent struct {
+	Version string      `json:"version"`
+	Creator harCreator  `json:"creator"`
+	Entries []*harEntry `json:"entries"`
+}
+
+type harCreator struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+type harEntry struct {
+	StartedDateTime string      `json:"startedDateTime"`
+	Time            float64     `json:"time"`
+	Request         harRequest  `json:"request"`
+	Response        harResponse `json:"response"`
+	Cache           struct{}    `json:"cache"`
+	Timings         harTimings  `json:"timings"`
+	// Error is the custom field Chrome uses for requests that never got
+	// a response.
+	Error string `json:"_error,omitempty"`
+}
+
+type harRequest struct {
+	Method      string      `json:"method"`
+	URL         string      `json:"url"`
+	HTTPVersion string      `json:"httpVersion"`
+	Cookies     []harCookie `json:"cookies"`
+	Headers     []harPair   `json:"headers"`
+	QueryString []harPair   `json:"queryString"`
+	HeadersSize int         `json:"headersSize"`
+	BodySize    int         `json:"bodySize"`
+}
+
+type harResponse struct {
+	Status      int         `json:"status"`
+	StatusText  string      `json:"statusText"`
+	HTTPVersion string      `json:"httpVersion"`
+	Cookies     []harCookie `json:"cookies"`
+	Headers     []harPair   `json:"headers"`
+	Content     harBody     `json:"content"`
+	RedirectURL string      `json:"redirectURL"`
+	HeadersSize int         `json:"headersSize"`
+	BodySize    int         `json:"bodySize"`
+}
+
+type harBody struct {
+	Size        int    `json:"size"`
+	Compression int    `json:"compression,omitempty"`
+	MimeType    string `json:"mimeType"`
+	Text        string `json:"text,omitempty"`
+	Encoding    string `json:"encoding,omitempty"`
+}
+
+type harPair struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type harCookie struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type harTimings struct {
+	Send    float64 `json:"send"`
+	Wait    float64 `json:"wait"`
+	Receive float64 `json:"receive"`
+}
+
+// HARRecorder is an http.RoundTripper that records every exchange passing
+// through it. An entry is completed when its response body is closed.
+type HARRecorder struct {
+	Transport http.RoundTripper
+	AllBodies bool
+
+	mu      sync.Mutex
+	entries []*harEntry
+}
+
+func NewHARRecorder(transport http.RoundTripper, allBodies bool) *HARRecorder {
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	return &HARRecorder{Transport: transport, AllBodies: allBodies}
+}
+
+func (r *HARRecorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	start := time.Now()
+	resp, err := r.Transport.RoundTrip(req)
+	wait := time.Since(start)
+
+	entry := &harEntry{
+		StartedDateTime: start.Format(time.RFC3339Nano),
+		Request: harRequest{
+			Method:      req.Method,
+			URL:         req.URL.String(),
+			HTTPVersion: req.Proto,
+			Cookies:     harCookies(req.Cookies()),
+			Headers:     harHeaders(req.Header),
+			QueryString: []harPair{},
+			HeadersSize: -1,
+			BodySize:    int(req.ContentLength),
+		},
+		Timings: harTimings{Wait: millis(wait)},
+		Time:    millis(wait),
+	}
+	for name, values := range req.URL.Query() {
+		for _, v := range values {
+			entry.Request.QueryString = append(entry.Request.QueryString, harPair{name, v})
+		}
+	}
+
+	if err != nil {
+		// Timeouts and refused connections are worth seeing in the HAR
+		// too, so they get an entry with status 0.
+		entry.Response = harResponse{
+			Cookies:     []harCookie{},
+			Headers:     []harPair{},
+			HeadersSize: -1,
+			BodySize:    -1,
+		}
+		entry.Error = err.Error()
+	} else {
+		entry.Request.HTTPVersion = resp.Proto
+		entry.Response = harResponse{
+			Status:      resp.StatusCode,
+			StatusText:  http.StatusText(resp.StatusCode),
+			HTTPVersion: resp.Proto,
+			Cookies:     harCookies(resp.Cookies()),
+			Headers:     harHeaders(resp.Header),
+			Content:     harBody{MimeType: resp.Header.Get("Content-Type")},
+			RedirectURL: resp.Header.Get("Location"),
+			HeadersSize: -1,
+		}
+	}
+
+	r.mu.Lock()
+	r.entries = append(r.entries, entry)
+	r.mu.Unlock()
+
+	if err != nil {
+		return resp, err
+	}
+	body := &harBodyReader{ReadCloser: resp.Body, rec: r, entry: entry, start: time.Now()}
+	body.content.all = r.AllBodies
+	if encodings := contentEncodings(resp.Header); len(encodings) > 0 {
+		body.decoder = newHARDecoder(encodings, r.AllBodies)
+	}
+	resp.Body = body
+	return resp, nil
+}
+
+// WriteFile writes the recorded exchanges as a HAR 1.2 document.
+func (r *HARRecorder) WriteFile(filename string) error {
+	r.mu.Lock()
+	doc := harLog{Log: harContent{
+		Version: "1.2",
+		Creator: harCreator{Name: "golang-url-crawler", Version: crawlerVersion},
+		Entries: r.entries,
+	}}
+	data, err := json.MarshalIndent(doc, "", "  ")
+	r.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filename, data, 0644)
+}
+
+type harBodyReader struct {
+	io.ReadCloser
+	rec   *HARRecorder
+	entry *harEntry
+	start time.Time
+	// size counts the bytes as sent, content what they decode to. With
+	// no Content-Encoding the two are the same and decoder is nil.
+	size    int
+	content harCapture
+	decoder *harDecoder
+	once    sync.Once
+}
+
+// harCapture keeps a response body up to harBodyThreshold, or all of it
+// with all set, and counts it either way.
+type harCapture struct {
+	all  bool
+	size int
+	buf  []byte
+	// truncated is set once the body outgrows what we are willing to keep.
+	truncated bool
+}
+
+func (c *harCapture) Write(p []byte) (int, error) {
+	c.size += len(p)
+	if !c.truncated && (c.all || len(c.buf)+len(p) <= harBodyThreshold) {
+		c.buf = append(c.buf, p...)
+	} else {
+		c.buf = nil
+		c.truncated = true
+	}
+	return len(p), nil
+}
+
+// harDecoder undoes a Content-Encoding as the body streams past, so the
+// HAR holds what DevTools expects to show rather than compressed bytes.
+type harDecoder struct {
+	pw      *io.PipeWriter
+	done    chan struct{}
+	content harCapture
+	failed  bool
+}
+
+func newHARDecoder(encodings []string, all bool) *harDecoder {
+	pr, pw := io.Pipe()
+	d := &harDecoder{pw: pw, done: make(chan struct{}), content: harCapture{all: all}}
+	go func() {
+		defer close(d.done)
+		r, err := newDecoders(encodings, pr)
+		if err == nil {
+			_, err = io.Copy(&d.content, r)
+		}
+		// A body the crawler stopped reading early ends mid-stream, which
+		// still leaves a usable prefix.
+		d.failed = err != nil && err != io.ErrUnexpectedEOF
+		// Whatever the decoder didn't consume must still be read, or the
+		// next Write would block forever.
+		io.Copy(io.Discard, pr)
+	}()
+	return d
+}
+
+func (b *harBodyReader) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.size += n
+	if b.decoder != nil {
+		b.decoder.pw.Write(p[:n])
+	} else {
+		b.content.Write(p[:n])
+	}
+	return n, err
+}
+
+func (b *harBodyReader) Close() error {
+	b.once.Do(func() {
+		receive := time.Since(b.start)
+		content := &b.content
+		if b.decoder != nil {
+			b.decoder.pw.Close()
+			<-b.decoder.done
+			content = &b.decoder.content
+			if b.decoder.failed {
+				content.buf, content.truncated = nil, true
+			}
+		}
+
+		b.rec.mu.Lock()
+		defer b.rec.mu.Unlock()
+		b.entry.Timings.Receive = millis(receive)
+		b.entry.Time += millis(receive)
+		b.entry.Response.BodySize = b.size
+		b.entry.Response.Content.Size = content.size
+		if b.decoder != nil {
+			b.entry.Response.Content.Compression = content.size - b.size
+		}
+		if !content.truncated && content.size > 0 {
+			if utf8.Valid(content.buf) {
+				b.entry.Response.Content.Text = string(content.buf)
+			} else {
+				b.entry.Response.Content.Text = base64.StdEncoding.EncodeToString(content.buf)
+				b.entry.Response.Content.Encoding = "base64"
+			}
+		}
+	})
+	return b.ReadCloser.Close()
+}
+
+func harHeaders(h http.Header) []harPair {
+	pairs := []harPair{}
+	for name, values := range h {
+		for _, v := range values {
+			pairs = append(pairs, harPair{name, v})
+		}
+	}
+	return pairs
+}
+
+func harCookies(cookies []*http.Cookie) []harCookie {
+	out := []harCookie{}
+	for _, c := range cookies {
+		out = append(out, harCookie{c.Name, c.Value})
+	}
+	return out
+}
+
+func millis(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
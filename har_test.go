@@ -0,0 +1,71 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestHARRecordsDecodedBody(t *testing.T) {
+	page := []byte(`<html><body><a href="/next">next</a>` + string(bytes.Repeat([]byte("<p>filler</p>"), 100)) + `</body></html>`)
+	var gz, zl bytes.Buffer
+	gw := gzip.NewWriter(&gz)
+	gw.Write(page)
+	gw.Close()
+	zw := zlib.NewWriter(&zl)
+	zw.Write(page)
+	zw.Close()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		switch r.URL.Path {
+		case "/gzip":
+			w.Header().Set("Content-Encoding", "gzip")
+			w.Write(gz.Bytes())
+		case "/deflate":
+			w.Header().Set("Content-Encoding", "deflate")
+			w.Write(zl.Bytes())
+		default:
+			w.Write(page)
+		}
+	}))
+	defer srv.Close()
+
+	f := NewHTTPFetcher()
+	har := NewHARRecorder(f.Client.Transport, false)
+	f.Client.Transport = har
+	wire := map[string]int{"/gzip": gz.Len(), "/deflate": zl.Len(), "/plain": len(page)}
+	for _, path := range []string{"/gzip", "/deflate", "/plain"} {
+		resp, err := f.Fetch(context.Background(), srv.URL+path)
+		if err != nil {
+			t.Fatalf("fetch %s: %v", path, err)
+		}
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+	}
+
+	if len(har.entries) != 3 {
+		t.Fatalf("recorded %d entries, want 3", len(har.entries))
+	}
+	for _, e := range har.entries {
+		path := e.Request.URL[len(srv.URL):]
+		c := e.Response.Content
+		if c.Text != string(page) || c.Encoding != "" {
+			t.Errorf("%s: content is not the decoded page (encoding %q, %d bytes)", path, c.Encoding, len(c.Text))
+		}
+		if c.Size != len(page) {
+			t.Errorf("%s: content.size = %d, want %d", path, c.Size, len(page))
+		}
+		if e.Response.BodySize != wire[path] {
+			t.Errorf("%s: bodySize = %d, want %d", path, e.Response.BodySize, wire[path])
+		}
+		if want := len(page) - wire[path]; c.Compression != want {
+			t.Errorf("%s: compression = %d, want %d", path, c.Compression, want)
+		}
+	}
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+)
+
+// headerLinks returns the URLs referenced by a response's Link and Refresh
+// headers, unresolved.
+func headerLinks(h http.Header) []string {
+	var links []string
+	for _, v := range h.Values("Link") {
+		links = append(links, parseLinkHeader(v)...)
+	}
+	for _, v := range h.Values("Refresh") {
+		if u := parseRefresh(v); u != "" {
+			links = append(links, u)
+		}
+	}
+	return links
+}
+
+// parseLinkHeader pulls the URI references out of an RFC 8288 Link header
+// value such as `</a>; rel="next", </b>; title="x, y"`. Commas inside
+// quoted parameters don't split entries.
+func parseLinkHeader(v string) []string {
+	var links []string
+	for len(v) > 0 {
+		start := strings.IndexByte(v, '<')
+		if start < 0 {
+			break
+		}
+		end := strings.IndexByte(v[start:], '>')
+		if end < 0 {
+			break
+		}
+		links = append(links, strings.TrimSpace(v[start+1:start+end]))
+		v = v[start+end+1:]
+
+		// Skip the parameters up to the next comma outside quotes.
+		i := unquotedComma(v)
+		if i < 0 {
+			break
+		}
+		v = v[i+1:]
+	}
+	return links
+}
+
+func unquotedComma(v string) int {
+	inQuote := false
+	for i := 0; i < len(v); i++ {
+		switch c := v[i]; {
+		case c == '\\' && inQuote:
+			i++
+		case c == '"':
+			inQuote = !inQuote
+		case c == ',' && !inQuote:
+			return i
+		}
+	}
+	return -1
+}
+
+// parseRefresh returns the URL from a Refresh header or meta refresh
+// content like `5; url=/foo` or `0;URL='/foo'`, or "" if there is none.
+func parseRefresh(content string) string {
+	rest := strings.TrimSpace(content)
+	// Some pages leave out the delay altogether. Otherwise it is a number,
+	// and only it is split off: the URL may hold ';' and ',' itself.
+	if after, ok := cutURLPrefix(rest); ok {
+		rest = after
+	} else {
+		delay := strings.TrimLeft(rest, "0123456789.")
+		if len(delay) == len(rest) {
+			return ""
+		}
+		rest = strings.TrimSpace(delay)
+		if strings.HasPrefix(rest, ";") || strings.HasPrefix(rest, ",") {
+			rest = strings.TrimSpace(rest[1:])
+		}
+		if after, ok := cutURLPrefix(rest); ok {
+			rest = after
+		}
+	}
+	if len(rest) > 1 && (rest[0] == '\'' || rest[0] == '"') {
+		if end := strings.IndexByte(rest[1:], rest[0]); end >= 0 {
+			rest = rest[1 : end+1]
+		} else {
+			rest = rest[1:]
+		}
+	}
+	return strings.TrimSpace(rest)
+}
+
+// cutURLPrefix strips a leading `url=`, in any case and with optional
+// spaces around the '='.
+func cutURLPrefix(s string) (string, bool) {
+	if len(s) < 3 || !strings.EqualFold(s[:3], "url") {
+		return s, false
+	}
+	after := strings.TrimSpace(s[3:])
+	if !strings.HasPrefix(after, "=") {
+		return s, false
+	}
+	return strings.TrimSpace(after[1:]), true
+}
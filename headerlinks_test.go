@@ -0,0 +1,168 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"golang.org/x/net/html"
+)
+
+func TestParseRefresh(t *testing.T) {
+	tests := []struct{ content, want string }{
+		{"0; url=/next", "/next"},
+		{"5;url=/next", "/next"},
+		{"5; URL=/next", "/next"},
+		{"5; Url = /next", "/next"},
+		{"0;URL='/x'", "/x"},
+		{`0; url="/x"`, "/x"},
+		{"0; url='/x", "/x"},
+		{"0; url=/next?a=b", "/next?a=b"},
+		{"5; url=/next?a=b=c&d=", "/next?a=b=c&d="},
+		{"0; url='https://example.com/p?q=1&r=2'", "https://example.com/p?q=1&r=2"},
+		{"3, url=/comma", "/comma"},
+		{"url=/nodelay", "/nodelay"},
+		{"url=/a;b", "/a;b"},
+		{"URL = /x,y", "/x,y"},
+		{"0; url=/a;b,c", "/a;b,c"},
+		{"1.5, /bare", "/bare"},
+		{"0; /bare", "/bare"},
+		{" 10 ;  url=  /spaced  ", "/spaced"},
+		{"0; url=/urlish=1", "/urlish=1"},
+		{"5", ""},
+		{"soon; url=/x", ""},
+		{"5;", ""},
+		{"", ""},
+		{"0; url=", ""},
+	}
+	for _, tt := range tests {
+		if got := parseRefresh(tt.content); got != tt.want {
+			t.Errorf("parseRefresh(%q) = %q, want %q", tt.content, got, tt.want)
+		}
+	}
+}
+
+func TestMetaRefreshLinks(t *testing.T) {
+	doc, err := html.Parse(strings.NewReader(`<html><head>
+<meta http-equiv="Refresh" content="0; URL='/moved?a=b=c'">
+<meta name="refresh" content="0; url=/not-a-refresh">
+<meta http-equiv="content-type" content="text/html; charset=utf-8">
+</head></html>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := NewCrawler(nil, nil)
+	var got []string
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.ElementNode && n.Data == "meta" {
+			got = append(got, c.extractLinks("https://example.com/dir/", n)...)
+		}
+		for child := n.FirstChild; child != nil; child = child.NextSibling {
+			walk(child)
+		}
+	}
+	walk(doc)
+	if want := []string{"https://example.com/moved?a=b=c"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("meta links = %q, want %q", got, want)
+	}
+}
+
+func TestParseLinkHeader(t *testing.T) {
+	tests := []struct {
+		value string
+		want  []string
+	}{
+		{`</a>`, []string{"/a"}},
+		{`</a>; rel="next", </b>; rel="prev"`, []string{"/a", "/b"}},
+		{`</a>;rel=preload,</b>;rel=preload,<https://cdn.example.com/c.js>`, []string{"/a", "/b", "https://cdn.example.com/c.js"}},
+		{`</a>; title="a, b", </b>`, []string{"/a", "/b"}},
+		{`</a>; title="x; y, <not-a-link>", </b>; rel=next`, []string{"/a", "/b"}},
+		{`</a>; title="say \"hi\", bye", </b>`, []string{"/a", "/b"}},
+		{`</a?x=1,2>; rel=next`, []string{"/a?x=1,2"}},
+		{`< /spaced >`, []string{"/spaced"}},
+		{`</a>; title="unterminated, </b>`, []string{"/a"}},
+		{`</unclosed`, nil},
+		{``, nil},
+	}
+	for _, tt := range tests {
+		if got := parseLinkHeader(tt.value); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseLinkHeader(%q) = %q, want %q", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestRefreshHeaderLinks(t *testing.T) {
+	h := http.Header{}
+	h.Add("Refresh", "5; url=/after?x=1=2")
+	h.Add("Link", `</style.css>; rel=preload; as=style`)
+	h.Add("Link", `</next>; rel="next"; title="page 2, of 3", </font.woff2>; rel=preload; as=font`)
+	h.Add("Link", `<https://example.com/api>; rel="alternate"; type="application/json"`)
+	want := []string{
+		"/style.css",
+		"/next",
+		"/font.woff2",
+		"https://example.com/api",
+		"/after?x=1=2",
+	}
+	if got := headerLinks(h); !reflect.DeepEqual(got, want) {
+		t.Errorf("headerLinks = %q, want %q", got, want)
+	}
+}
+
+func TestScriptLinkHeaders(t *testing.T) {
+	pages := map[string]struct {
+		header http.Header
+		body   string
+	}{
+		"http://site.test/": {
+			http.Header{"Content-Type": {"text/html"}},
+			`<html><body><script src="/app.js"></script></body></html>`,
+		},
+		"http://site.test/app.js": {
+			http.Header{
+				"Content-Type": {"application/javascript"},
+				"Link": {
+					`</chunk.js>; rel="preload modulepreload"; as=script, </app.css>; rel=preload; as=style`,
+					`<https://cdn.test/lib.js>; rel="preload prefetch"`,
+				},
+			},
+			`console.log("app")`,
+		},
+	}
+	fetcher := FetcherFunc(func(ctx context.Context, url string) (*http.Response, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		if err != nil {
+			return nil, err
+		}
+		resp := &http.Response{Request: req, StatusCode: http.StatusNotFound, Header: http.Header{}, Body: http.NoBody}
+		if p, ok := pages[url]; ok {
+			resp.StatusCode, resp.Header = http.StatusOK, p.header
+			resp.Body = io.NopCloser(strings.NewReader(p.body))
+		}
+		return resp, nil
+	})
+
+	c := NewCrawler([]string{"site.test"}, nil)
+	c.Fetcher = fetcher
+	res, err := c.Run(context.Background(), []string{"http://site.test/"})
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+
+	var got []string
+	for _, d := range append(res.InScope, res.OutScope...) {
+		if d.Source == "http://site.test/app.js" {
+			got = append(got, d.URL)
+		}
+	}
+	sort.Strings(got)
+	want := []string{"http://site.test/app.css", "http://site.test/chunk.js", "https://cdn.test/lib.js"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("URLs found via app.js = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"golang.org/x/net/http/httpguts"
+)
+
+// headerFlags collects repeated -H "Name: value" flags.
+type headerFlags []string
+
+func (h *headerFlags) String() string {
+	return strings.Join(*h, ", ")
+}
+
+func (h *headerFlags) Set(value string) error {
+	*h = append(*h, value)
+	return nil
+}
+
+// Redacted lists the header names without their values, which are often
+// tokens.
+func (h *headerFlags) Redacted() string {
+	lines := make([]string, len(*h))
+	for i, line := range *h {
+		name, _, _ := strings.Cut(line, ":")
+		lines[i] = strings.TrimSpace(name) + ": REDACTED"
+	}
+	return strings.Join(lines, ", ")
+}
+
+// parseHeaders turns "Name: value" lines into a header set. Only the first
+// colon separates name from value, and a name given twice keeps both values.
+func parseHeaders(lines []string) (http.Header, error) {
+	header := make(http.Header)
+	for _, line := range lines {
+		name, value, ok := strings.Cut(line, ":")
+		if !ok {
+			return nil, fmt.Errorf("header %q is not in the form \"Name: value\"", line)
+		}
+		name, value = strings.TrimSpace(name), strings.TrimSpace(value)
+		if !httpguts.ValidHeaderFieldName(name) {
+			return nil, fmt.Errorf("invalid header name %q", name)
+		}
+		if !httpguts.ValidHeaderFieldValue(value) {
+			return nil, fmt.Errorf("invalid value for header %s", name)
+		}
+		header.Add(name, value)
+	}
+	return header, nil
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func TestHeadersSent(t *testing.T) {
+	var flags headerFlags
+	for _, h := range []string{
+		"X-Bug-Bounty: researcher",
+		"X-Forwarded-For:127.0.0.1",
+		"X-Time: 12:30:45",
+		"X-Multi: one",
+		"x-multi: two",
+		"User-Agent: custom-agent",
+	} {
+		flags.Set(h)
+	}
+	header, err := parseHeaders(flags)
+	if err != nil {
+		t.Fatalf("parseHeaders: %v", err)
+	}
+
+	var got http.Header
+	srv, _ := newCountingServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header.Clone()
+	}), false)
+	f := NewHTTPFetcher()
+	f.Header = header
+	resp, err := f.Fetch(context.Background(), srv.URL+"/")
+	if err != nil {
+		t.Fatalf("fetch: %v", err)
+	}
+	resp.Body.Close()
+
+	want := map[string][]string{
+		"X-Bug-Bounty":    {"researcher"},
+		"X-Forwarded-For": {"127.0.0.1"},
+		"X-Time":          {"12:30:45"},
+		"X-Multi":         {"one", "two"},
+		"User-Agent":      {"custom-agent"},
+	}
+	for name, values := range want {
+		if !reflect.DeepEqual(got.Values(name), values) {
+			t.Errorf("server got %s = %q, want %q", name, got.Values(name), values)
+		}
+	}
+}
+
+func TestParseHeadersInvalid(t *testing.T) {
+	for _, line := range []string{
+		"no colon here",
+		": empty name",
+		"Bad Name: value",
+		"X-Bad\x00: value",
+		"X-Value: bad\nvalue",
+	} {
+		if _, err := parseHeaders([]string{line}); err == nil {
+			t.Errorf("parseHeaders(%q) succeeded, want an error", line)
+		}
+	}
+}
+
+func TestHeaderFlagsRedacted(t *testing.T) {
+	flags := headerFlags{"Authorization: Bearer secret", "X-Api-Key:key"}
+	if got, want := flags.Redacted(), "Authorization: REDACTED, X-Api-Key: REDACTED"; got != want {
+		t.Errorf("Redacted() = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"mime"
+	"sort"
+	"strings"
+)
+
+// maxJSONDepth is how deeply nested a JSON document is walked for URLs.
+// Anything deeper is almost certainly not worth the time.
+const maxJSONDepth = 64
+
+// isJSON reports whether a response is JSON, by content type (including
+// the +json types such as ld+json) or, failing that, by parsing it.
+func isJSON(contentType string, body []byte) bool {
+	if mediaType, _, err := mime.ParseMediaType(contentType); err == nil &&
+		(mediaType == "application/json" || strings.HasSuffix(mediaType, "+json")) {
+		return true
+	}
+	trimmed := bytes.TrimSpace(body)
+	if len(trimmed) == 0 || trimmed[0] != '{' && trimmed[0] != '[' {
+		return false
+	}
+	return json.Valid(trimmed)
+}
+
+// jsonLinks returns every string value in a JSON document that is an
+// http(s) URL or looks like an absolute path, as in API responses full of
+// "href": "/users/42" or JSON-LD's "url" properties. Invalid JSON has no
+// links.
+func jsonLinks(data []byte) []string {
+	var doc interface{}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil
+	}
+	var links []string
+	walkJSON(doc, 0, func(s string) {
+		if s = strings.TrimSpace(s); looksLikeLink(s) {
+			links = append(links, s)
+		}
+	})
+	return links
+}
+
+// looksLikeLink reports whether a bare string is an http(s) URL or an
+// absolute path.
+func looksLikeLink(s string) bool {
+	return strings.HasPrefix(s, "http://") || strings.HasPrefix(s, "https://") ||
+		strings.HasPrefix(s, "/") && isScriptPath(s)
+}
+
+func walkJSON(v interface{}, depth int, visit func(string)) {
+	if depth > maxJSONDepth {
+		return
+	}
+	switch v := v.(type) {
+	case string:
+		visit(v)
+	case []interface{}:
+		for _, item := range v {
+			walkJSON(item, depth+1, visit)
+		}
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			walkJSON(v[key], depth+1, visit)
+		}
+	}
+}
@@ -0,0 +1,25 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+)
+
+// jsonlWriter writes one line per result as soon as it arrives. Each record
+// goes out in a single Write so a tailing reader never sees half a line.
+// After the first write error it drops everything so the crawl never blocks.
+type jsonlWriter struct {
+	w   io.Writer
+	err error
+}
+
+func (j *jsonlWriter) Add(r URLResult) {
+	if j.err != nil {
+		return
+	}
+	line, err := json.Marshal(r)
+	if err == nil {
+		_, err = j.w.Write(append(line, '\n'))
+	}
+	j.err = err
+}
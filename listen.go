@@ -0,0 +1,166 @@
+package main
+
+import (
+	"encoding/json"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+// listenClientBuffer is how many results a client may fall behind before we
+// start dropping results for it rather than stalling the crawl.
+const listenClientBuffer = 1024
+
+// listenCloseGrace is how long Close waits for clients to read what is
+// left before hanging up on them.
+const listenCloseGrace = 5 * time.Second
+
+// resultBroadcaster serves the result stream as newline delimited JSON to
+// every client connected to a TCP address or, with a "unix:" prefix, a Unix
+// socket.
+type resultBroadcaster struct {
+	ln     net.Listener
+	replay bool
+
+	mu      sync.Mutex
+	clients map[*streamClient]bool
+	history [][]byte
+	closed  bool
+	wg      sync.WaitGroup
+}
+
+type streamClient struct {
+	conn    net.Conn
+	lines   chan []byte
+	dropped int
+}
+
+func newResultBroadcaster(addr string, replay bool) (*resultBroadcaster, error) {
+	network := "tcp"
+	if strings.HasPrefix(addr, "unix:") {
+		network, addr = "unix", strings.TrimPrefix(addr, "unix:")
+	}
+	ln, err := net.Listen(network, addr)
+	if err != nil {
+		return nil, err
+	}
+
+	b := &resultBroadcaster{ln: ln, replay: replay, clients: make(map[*streamClient]bool)}
+	go b.acceptLoop()
+	return b, nil
+}
+
+func (b *resultBroadcaster) acceptLoop() {
+	for {
+		conn, err := b.ln.Accept()
+		if err != nil {
+			return
+		}
+
+		c := &streamClient{conn: conn, lines: make(chan []byte, listenClientBuffer)}
+		b.mu.Lock()
+		if b.closed {
+			b.mu.Unlock()
+			conn.Close()
+			return
+		}
+		// Registering and snapshotting under the same lock means the
+		// client sees every result exactly once.
+		var backlog [][]byte
+		if b.replay {
+			backlog = append(backlog, b.history...)
+		}
+		b.clients[c] = true
+		b.wg.Add(1)
+		b.mu.Unlock()
+
+		infof("Result stream client connected from %s", conn.RemoteAddr())
+		go b.serve(c, backlog)
+	}
+}
+
+func (b *resultBroadcaster) serve(c *streamClient, backlog [][]byte) {
+	defer b.wg.Done()
+	defer c.conn.Close()
+
+	for _, line := range backlog {
+		if _, err := c.conn.Write(line); err != nil {
+			b.drop(c)
+			return
+		}
+	}
+	for line := range c.lines {
+		if _, err := c.conn.Write(line); err != nil {
+			b.drop(c)
+			return
+		}
+	}
+}
+
+// drop forgets a client whose connection has gone away.
+func (b *resultBroadcaster) drop(c *streamClient) {
+	b.mu.Lock()
+	if b.clients[c] {
+		delete(b.clients, c)
+		close(c.lines)
+	}
+	b.mu.Unlock()
+	infof("Result stream client %s disconnected", c.conn.RemoteAddr())
+}
+
+func (b *resultBroadcaster) Add(r URLResult) {
+	line, err := json.Marshal(r)
+	if err != nil {
+		return
+	}
+	line = append(line, '\n')
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.replay {
+		b.history = append(b.history, line)
+	}
+	for c := range b.clients {
+		select {
+		case c.lines <- line:
+		default:
+			c.dropped++
+		}
+	}
+}
+
+// Close stops accepting clients, gives connected ones listenCloseGrace to
+// drain what they have buffered and reports anything that had to be
+// dropped. Clients still not done by then are disconnected, so one that
+// never reads can't keep the process from exiting.
+func (b *resultBroadcaster) Close() {
+	b.ln.Close()
+
+	b.mu.Lock()
+	b.closed = true
+	var conns []net.Conn
+	for c := range b.clients {
+		if c.dropped > 0 {
+			errorf("Dropped %d results for slow client %s", c.dropped, c.conn.RemoteAddr())
+		}
+		delete(b.clients, c)
+		close(c.lines)
+		conns = append(conns, c.conn)
+	}
+	b.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		b.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(listenCloseGrace):
+		for _, conn := range conns {
+			conn.Close()
+		}
+		<-done
+	}
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"log/slog"
+	"os"
+	"strings"
+)
+
+type logLevel int
+
+const (
+	levelQuiet logLevel = iota
+	levelProgress
+	levelVerbose
+)
+
+// verbosity is set once from -q/-v before the crawl starts.
+var verbosity = levelProgress
+
+// jsonLogger replaces the plain log lines when -log-format json is given.
+var jsonLogger *slog.Logger
+
+func setLogFormat(format string) error {
+	switch format {
+	case "text":
+		jsonLogger = nil
+	case "json":
+		jsonLogger = slog.New(slog.NewJSONHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	default:
+		return fmt.Errorf("unknown log format %q", format)
+	}
+	return nil
+}
+
+// errorf logs failures, which are shown at every level including -q.
+func errorf(format string, v ...interface{}) {
+	logf(slog.LevelError, format, v...)
+}
+
+// infof logs crawl progress, shown unless -q is given.
+func infof(format string, v ...interface{}) {
+	if verbosity >= levelProgress {
+		logf(slog.LevelInfo, format, v...)
+	}
+}
+
+// verbosef logs per-URL detail such as every link found, only shown with -v.
+func verbosef(format string, v ...interface{}) {
+	if verbosity >= levelVerbose {
+		logf(slog.LevelDebug, format, v...)
+	}
+}
+
+func fatalf(format string, v ...interface{}) {
+	logf(slog.LevelError, format, v...)
+	os.Exit(1)
+}
+
+func logf(level slog.Level, format string, v ...interface{}) {
+	msg := fmt.Sprintf(format, v...)
+	if jsonLogger == nil {
+		log.Print(msg)
+		return
+	}
+	var attrs []interface{}
+	if u := logURL(v); u != "" {
+		attrs = append(attrs, "url", u)
+	}
+	jsonLogger.Log(context.Background(), level, msg, attrs...)
+}
+
+// logURL picks the URL a message is about: the first argument that is an
+// absolute http(s) URL.
+func logURL(v []interface{}) string {
+	for _, arg := range v {
+		var s string
+		switch a := arg.(type) {
+		case string:
+			s = a
+		case fmt.Stringer:
+			s = a.String()
+		default:
+			continue
+		}
+		if strings.HasPrefix(s, "http://") || strings.HasPrefix(s, "https://") {
+			return s
+		}
+	}
+	return ""
+}
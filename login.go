@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// LoginOptions describes a single authentication request made before the
+// crawl so its session cookies land in the shared jar.
+type LoginOptions struct {
+	URL    string
+	Method string
+	// Data is the urlencoded form body. If TokenRegex is set, the login
+	// page is fetched first and "{token}" in Data is replaced with the
+	// regex's first capture group (or the whole match without one).
+	Data       string
+	TokenRegex *regexp.Regexp
+}
+
+func (f *HTTPFetcher) Login(ctx context.Context, opts LoginOptions) error {
+	data := opts.Data
+	if opts.TokenRegex != nil {
+		token, err := f.loginToken(ctx, opts.URL, opts.TokenRegex)
+		if err != nil {
+			return err
+		}
+		data = strings.ReplaceAll(data, "{token}", url.QueryEscape(token))
+	}
+
+	method := strings.ToUpper(opts.Method)
+	target := opts.URL
+	var body io.Reader
+	if method == "GET" {
+		if data != "" {
+			sep := "?"
+			if strings.Contains(target, "?") {
+				sep = "&"
+			}
+			target += sep + data
+		}
+	} else {
+		body = strings.NewReader(data)
+	}
+
+	req, err := f.newRequest(ctx, method, target, body)
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	}
+
+	resp, err := f.Client.Do(req)
+	if err != nil {
+		return fmt.Errorf("login request to %s failed: %w", opts.URL, err)
+	}
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 399 {
+		return fmt.Errorf("login to %s returned status %d", opts.URL, resp.StatusCode)
+	}
+	return nil
+}
+
+func (f *HTTPFetcher) loginToken(ctx context.Context, loginURL string, re *regexp.Regexp) (string, error) {
+	req, err := f.newRequest(ctx, "GET", loginURL, nil)
+	if err != nil {
+		return "", err
+	}
+	resp, err := f.Client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("fetching login page %s: %w", loginURL, err)
+	}
+	defer resp.Body.Close()
+
+	if err := decodeBody(resp); err != nil {
+		return "", fmt.Errorf("decoding login page %s: %w", loginURL, err)
+	}
+	page, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("reading login page %s: %w", loginURL, err)
+	}
+
+	m := re.FindSubmatch(page)
+	switch {
+	case m == nil:
+		return "", fmt.Errorf("login token regex %q did not match %s", re, loginURL)
+	case len(m) > 1:
+		return string(m[1]), nil
+	}
+	return string(m[0]), nil
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// viaManifest tags URLs found in a web app manifest.
+const viaManifest = "manifest"
+
+// webManifest holds the URL fields of a web app manifest. Relative URLs in
+// it are relative to the manifest, not the page linking it.
+type webManifest struct {
+	StartURL    string          `json:"start_url"`
+	Scope       string          `json:"scope"`
+	Icons       []manifestImage `json:"icons"`
+	Screenshots []manifestImage `json:"screenshots"`
+	Shortcuts   []struct {
+		URL   string          `json:"url"`
+		Icons []manifestImage `json:"icons"`
+	} `json:"shortcuts"`
+	ServiceWorker struct {
+		Src string `json:"src"`
+	} `json:"serviceworker"`
+	RelatedApplications []struct {
+		URL string `json:"url"`
+	} `json:"related_applications"`
+}
+
+type manifestImage struct {
+	Src string `json:"src"`
+}
+
+// linkRel is a <link> element's resolved href and its rel value.
+type linkRel struct {
+	URL string
+	Rel string
+}
+
+// linkRels returns every <link href> in the document with its rel, so
+// preloads, alternates, manifests and the like can be told apart.
+func (c *Crawler) linkRels(base string, n *html.Node) []linkRel {
+	var links []linkRel
+	if n.Type == html.ElementNode && n.Data == "link" {
+		if href := attr(n, "href"); href != "" {
+			rel := strings.ToLower(strings.Join(strings.Fields(attr(n, "rel")), " "))
+			links = append(links, linkRel{URL: c.formatURL(base, href), Rel: rel})
+		}
+	}
+	for child := n.FirstChild; child != nil; child = child.NextSibling {
+		links = append(links, c.linkRels(base, child)...)
+	}
+	return links
+}
+
+func (l linkRel) is(rel string) bool {
+	for _, r := range strings.Fields(l.Rel) {
+		if r == rel {
+			return true
+		}
+	}
+	return false
+}
+
+// manifestLinks fetches an in-scope web app manifest and returns its
+// start_url, scope, icons and other URLs, resolved against it.
+func (c *Crawler) manifestLinks(ctx context.Context, manifestURL string) []string {
+	if !c.isInScope(manifestURL) {
+		return nil
+	}
+	data := c.fetchResource(ctx, manifestURL, "manifest")
+	if data == nil {
+		return nil
+	}
+	var m webManifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		verbosef("Invalid manifest %s: %v", manifestURL, err)
+		return nil
+	}
+
+	refs := []string{m.StartURL, m.Scope, m.ServiceWorker.Src}
+	for _, images := range [][]manifestImage{m.Icons, m.Screenshots} {
+		for _, img := range images {
+			refs = append(refs, img.Src)
+		}
+	}
+	for _, s := range m.Shortcuts {
+		refs = append(refs, s.URL)
+		for _, img := range s.Icons {
+			refs = append(refs, img.Src)
+		}
+	}
+	for _, app := range m.RelatedApplications {
+		refs = append(refs, app.URL)
+	}
+
+	var links []string
+	for _, ref := range refs {
+		if ref = strings.TrimSpace(ref); ref != "" {
+			links = append(links, c.formatURL(manifestURL, ref))
+		}
+	}
+	return links
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+func TestCrawlWebManifest(t *testing.T) {
+	const manifest = `{
+  "start_url": "../app/start?utm_source=pwa",
+  "scope": "/app/",
+  "icons": [{"src": "icons/192.png", "sizes": "192x192"}],
+  "shortcuts": [{"name": "Compose", "url": "/compose"}],
+  "serviceworker": {"src": "sw.js"}
+}`
+	res, base := crawlTestServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/":
+			fmt.Fprint(w, `<html><head><link rel="manifest" href="/static/app.webmanifest"></head></html>`)
+		case "/static/app.webmanifest":
+			w.Header().Set("Content-Type", "application/manifest+json")
+			fmt.Fprint(w, manifest)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+
+	// Relative URLs resolve against the manifest, not the page.
+	for _, want := range []string{
+		base + "/app/start?utm_source=pwa",
+		base + "/app/",
+		base + "/static/icons/192.png",
+		base + "/compose",
+		base + "/static/sw.js",
+	} {
+		if !hasInScope(res, want) {
+			t.Errorf("%s not found; in scope: %v", want, res.InScope)
+		}
+	}
+	if hasInScope(res, base+"/icons/192.png") {
+		t.Error("icon resolved against the page instead of the manifest")
+	}
+}
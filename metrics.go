@@ -0,0 +1,80 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// durationBuckets are the upper bounds, in seconds, of the request duration
+// histogram.
+var durationBuckets = [...]float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30}
+
+// durationHistogram is a minimal Prometheus style histogram.
+type durationHistogram struct {
+	mu     sync.Mutex
+	counts [len(durationBuckets)]uint64 // per bucket, not cumulative
+	sum    float64
+	count  uint64
+}
+
+func (h *durationHistogram) Observe(d time.Duration) {
+	s := d.Seconds()
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for i, le := range durationBuckets {
+		if s <= le {
+			h.counts[i]++
+			break
+		}
+	}
+	h.sum += s
+	h.count++
+}
+
+func (h *durationHistogram) write(w *bufio.Writer, name string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	var cumulative uint64
+	for i, le := range durationBuckets {
+		cumulative += h.counts[i]
+		fmt.Fprintf(w, "%s_bucket{le=\"%g\"} %d\n", name, le, cumulative)
+	}
+	fmt.Fprintf(w, "%s_bucket{le=\"+Inf\"} %d\n", name, h.count)
+	fmt.Fprintf(w, "%s_sum %g\n", name, h.sum)
+	fmt.Fprintf(w, "%s_count %d\n", name, h.count)
+}
+
+// serveMetrics exposes the crawler's counters in the Prometheus text format
+// on addr under /metrics until the process exits.
+func serveMetrics(addr string, c *Crawler) error {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/metrics", func(rw http.ResponseWriter, r *http.Request) {
+		rw.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		w := bufio.NewWriter(rw)
+		fmt.Fprintln(w, "# HELP crawler_pages_total Pages fetched.")
+		fmt.Fprintln(w, "# TYPE crawler_pages_total counter")
+		fmt.Fprintf(w, "crawler_pages_total %d\n", c.Stats.Pages.Load())
+		fmt.Fprintln(w, "# HELP crawler_errors_total URLs that could not be fetched or read.")
+		fmt.Fprintln(w, "# TYPE crawler_errors_total counter")
+		fmt.Fprintf(w, "crawler_errors_total %d\n", c.Stats.Errors.Load())
+		fmt.Fprintln(w, "# HELP crawler_queue_depth URLs waiting in the crawl queue.")
+		fmt.Fprintln(w, "# TYPE crawler_queue_depth gauge")
+		fmt.Fprintf(w, "crawler_queue_depth %d\n", c.frontier.len())
+		fmt.Fprintln(w, "# HELP crawler_request_duration_seconds Time taken by each fetch.")
+		fmt.Fprintln(w, "# TYPE crawler_request_duration_seconds histogram")
+		c.Stats.RequestDurations.write(w, "crawler_request_duration_seconds")
+		w.Flush()
+	})
+
+	go http.Serve(ln, mux)
+	return nil
+}
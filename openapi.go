@@ -0,0 +1,146 @@
+package main
+
+import (
+	"context"
+	"net/url"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// viaOpenAPI tags endpoints listed in an OpenAPI or Swagger document.
+const viaOpenAPI = "openapi"
+
+// apiSpec holds the parts of a Swagger 2 or OpenAPI 3 document that make
+// up endpoint URLs. yaml.v3 reads the JSON form as well.
+type apiSpec struct {
+	Swagger  string   `yaml:"swagger"`
+	OpenAPI  string   `yaml:"openapi"`
+	Host     string   `yaml:"host"`
+	BasePath string   `yaml:"basePath"`
+	Schemes  []string `yaml:"schemes"`
+	Servers  []struct {
+		URL       string `yaml:"url"`
+		Variables map[string]struct {
+			Default string `yaml:"default"`
+		} `yaml:"variables"`
+	} `yaml:"servers"`
+	Paths map[string]interface{} `yaml:"paths"`
+}
+
+var pathParamRegex = regexp.MustCompile(`\{[^{}/]+\}`)
+
+// isAPISpec reports whether u looks like the address of an API
+// description: swagger.json, openapi.yaml and the like, or the api-docs
+// paths Springfox and springdoc serve them from.
+func isAPISpec(u string) bool {
+	parsed, err := url.Parse(u)
+	if err != nil {
+		return false
+	}
+	p := strings.ToLower(parsed.Path)
+	switch path.Base(p) {
+	case "swagger.json", "swagger.yaml", "swagger.yml", "openapi.json", "openapi.yaml", "openapi.yml":
+		return true
+	}
+	return strings.HasSuffix(p, "/api-docs") || strings.HasSuffix(p, "/api-docs.json") ||
+		strings.HasSuffix(p, "/api-docs.yaml")
+}
+
+// apiSpecLinks fetches an in-scope API description and returns the URL
+// of every path on every server it lists.
+func (c *Crawler) apiSpecLinks(ctx context.Context, specURL string) []string {
+	if !c.isInScope(specURL) {
+		return nil
+	}
+	data := c.fetchResource(ctx, specURL, "API description")
+	if data == nil {
+		return nil
+	}
+	var spec apiSpec
+	if err := yaml.Unmarshal(data, &spec); err != nil {
+		verbosef("Invalid API description %s: %v", specURL, err)
+		return nil
+	}
+	if spec.Swagger == "" && spec.OpenAPI == "" {
+		verbosef("%s is not a Swagger or OpenAPI document", specURL)
+		return nil
+	}
+	endpoints := spec.endpoints(specURL, c.APIPlaceholder)
+	infof("Found %d endpoints in API description %s", len(endpoints), specURL)
+	return endpoints
+}
+
+// endpoints joins each path to each base URL. Path templates like {id} are
+// kept as they are unless placeholder is set, in which case it replaces
+// them.
+func (s *apiSpec) endpoints(specURL, placeholder string) []string {
+	paths := make([]string, 0, len(s.Paths))
+	for p := range s.Paths {
+		if strings.HasPrefix(p, "/") {
+			paths = append(paths, p)
+		}
+	}
+	sort.Strings(paths)
+
+	var urls []string
+	seen := make(map[string]bool)
+	for _, base := range s.baseURLs(specURL) {
+		base = strings.TrimSuffix(base, "/")
+		for _, p := range paths {
+			if placeholder != "" {
+				p = pathParamRegex.ReplaceAllString(p, placeholder)
+			}
+			if u := base + p; !seen[u] {
+				seen[u] = true
+				urls = append(urls, u)
+			}
+		}
+	}
+	return urls
+}
+
+// baseURLs returns the absolute URLs the spec's paths are relative to.
+// Anything the spec leaves out is taken from the URL it was served from.
+func (s *apiSpec) baseURLs(specURL string) []string {
+	spec, err := url.Parse(specURL)
+	if err != nil {
+		return nil
+	}
+
+	if s.OpenAPI != "" {
+		var bases []string
+		for _, server := range s.Servers {
+			u := server.URL
+			for name, v := range server.Variables {
+				u = strings.ReplaceAll(u, "{"+name+"}", v.Default)
+			}
+			if ref, err := url.Parse(u); err == nil {
+				bases = append(bases, spec.ResolveReference(ref).String())
+			}
+		}
+		if len(bases) == 0 {
+			bases = append(bases, spec.Scheme+"://"+spec.Host)
+		}
+		return bases
+	}
+
+	host := s.Host
+	if host == "" {
+		host = spec.Host
+	}
+	schemes := s.Schemes
+	if len(schemes) == 0 {
+		schemes = []string{spec.Scheme}
+	}
+	var bases []string
+	for _, scheme := range schemes {
+		if scheme == "http" || scheme == "https" {
+			bases = append(bases, scheme+"://"+host+"/"+strings.TrimPrefix(s.BasePath, "/"))
+		}
+	}
+	return bases
+}
@@ -0,0 +1,767 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"flag"
+	"fmt"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// options holds the command line settings. register binds every field to
+// its flag, so a -config file, which is keyed by flag name, fills it in too.
+type options struct {
+	Verbose   bool
+	Quiet     bool
+	LogFormat string
+	Config    string
+
+	URL      string
+	Output   string
+	Format   string
+	InScope  string
+	OutScope string
+
+	BurpOut       string
+	IncludeBodies bool
+	HAR           string
+	HARBodies     bool
+
+	BasicAuth     string
+	Bearer        string
+	UserAgent     string
+	UserAgentFile string
+	Headers       headerFlags
+	HeadersFile   string
+	Cookie        string
+	CookiesFile   string
+
+	Graph         string
+	GraphJSON     string
+	GraphByHost   bool
+	GraphMaxNodes int
+
+	SaveResponses  string
+	SaveDir        string
+	SitemapOut     string
+	SitemapQueries bool
+
+	Listen       string
+	ListenReplay bool
+
+	Timestamps        bool
+	Subdomains        bool
+	SubdomainsInScope bool
+	FuzzLists         bool
+	Assets            bool
+	Wordlist          string
+	DB                string
+	Webhook           string
+	WebhookInterval   time.Duration
+	SummaryJSON       bool
+
+	MaxBodySize       int64
+	Proxy             string
+	ProxyInsecure     bool
+	Insecure          bool
+	CACert            string
+	ClientCert        string
+	ClientKey         string
+	MaxRedirects      int
+	NoReferer         bool
+	NoFollowRedirects bool
+	HTTP1             bool
+	HTTP2             bool
+	Priorities        priorityFlags
+	ResolveHosts      bool
+	ResolveWorkers    int
+	Resolve           resolveFlags
+	DNS               string
+	RequestTimeout    time.Duration
+	Metrics           string
+
+	State         string
+	StateInterval time.Duration
+	CacheDir      string
+	CacheMaxAge   time.Duration
+	NoCache       bool
+
+	CodeExts         string
+	UseSitemaps      bool
+	DataAttrs        bool
+	ExtraAttrs       string
+	LazyAttrs        string
+	CrawlGetForms    bool
+	ScanSecrets      bool
+	SecretRules      string
+	ObfuscatedEmails bool
+	DedupContent     bool
+	Order            string
+	Workers          int
+	Adaptive         bool
+	MinWorkers       int
+	MaxWorkers       int
+	HeadFirst        bool
+	WellKnown        bool
+	WellKnownFile    string
+	DataURIs         bool
+	APIPlaceholder   string
+	PDF              bool
+	Delay            time.Duration
+	Jitter           time.Duration
+	MaxBytes         string
+	MaxBandwidth     string
+
+	LoginURL        string
+	LoginData       string
+	LoginMethod     string
+	LoginTokenRegex string
+}
+
+// register defines the command line flags on fs.
+func (o *options) register(fs *flag.FlagSet) {
+	fs.BoolVar(&o.Verbose, "v", false, "Verbose: also log every URL found")
+	fs.BoolVar(&o.Quiet, "q", false, "Quiet: only log errors")
+	fs.StringVar(&o.LogFormat, "log-format", "text", "Log format: text or json (one object per line with level, time, msg and url)")
+	fs.StringVar(&o.Config, "config", "", "Load settings from a YAML or JSON file keyed by flag name; command line flags take precedence")
+	fs.StringVar(&o.URL, "url", "", "URL to start crawling from")
+	fs.StringVar(&o.Output, "output", "output.txt", "Output file to write URLs to")
+	fs.StringVar(&o.Format, "format", "text", "Output format: text (separate in/out of scope files) or jsonl (streamed to -output)")
+	fs.StringVar(&o.InScope, "inscope", "", "Comma-separated list of in-scope hosts: example.com (suffix), =example.com (exact) or *.example.com (subdomains only)")
+	fs.StringVar(&o.OutScope, "outscope", "", "Comma-separated list of out-of-scope hosts, same syntax as -inscope")
+	fs.StringVar(&o.BurpOut, "burp-out", "", "Write fetched in-scope URLs as Burp sitemap XML to this file")
+	fs.BoolVar(&o.IncludeBodies, "include-bodies", false, "Include base64 encoded requests and responses in the Burp XML")
+	fs.StringVar(&o.HAR, "har", "", "Record every request and response to this HAR file")
+	fs.StringVar(&o.BasicAuth, "basic-auth", "", "Send HTTP basic auth as user:pass to in-scope hosts")
+	fs.StringVar(&o.Bearer, "bearer", "", "Send this bearer token to in-scope hosts")
+	fs.StringVar(&o.UserAgent, "user-agent", defaultUserAgent, "User-Agent header to send")
+	fs.StringVar(&o.UserAgentFile, "user-agent-file", "", "Pick a random User-Agent per request from this file, one per line")
+	fs.StringVar(&o.UserAgentFile, "user-agents", "", "Same as -user-agent-file")
+	fs.Var(&o.Headers, "H", "Extra request header \"Name: value\" sent to in-scope hosts (repeatable)")
+	fs.StringVar(&o.HeadersFile, "headers-file", "", "Read extra request headers from this file, one \"Name: value\" per line")
+	fs.StringVar(&o.Cookie, "cookie", "", "Cookies to send to the seed URL's host, as \"name=value; other=value\"")
+	fs.StringVar(&o.CookiesFile, "cookies", "", "Load initial cookies from a Netscape format cookies.txt file")
+	fs.BoolVar(&o.HARBodies, "har-bodies", false, "Include all response bodies in the HAR, not just small ones")
+	fs.StringVar(&o.Graph, "graph", "", "Write the link graph as a Graphviz DOT file")
+	fs.StringVar(&o.GraphJSON, "graph-json", "", "Write the link graph as JSON nodes and edges")
+	fs.BoolVar(&o.GraphByHost, "graph-by-host", false, "Collapse the link graph to one node per host")
+	fs.IntVar(&o.GraphMaxNodes, "graph-max-nodes", 5000, "Only export the first N graph nodes (0 for no limit)")
+	fs.StringVar(&o.SaveResponses, "save-responses", "", "Mirror every fetched response body into this directory")
+	fs.StringVar(&o.SaveDir, "save-dir", "", "Save every fetched response body into this directory, named by the hash of its URL")
+	fs.StringVar(&o.SitemapOut, "sitemap-out", "", "Write in-scope HTML pages as a sitemap.xml to this file")
+	fs.BoolVar(&o.SitemapQueries, "sitemap-include-queries", false, "Keep URLs with query strings in the sitemap")
+	fs.StringVar(&o.Listen, "listen", "", "Serve results as JSON lines to clients connecting to this address (host:port or unix:/path)")
+	fs.BoolVar(&o.ListenReplay, "listen-replay", false, "Send clients that connect mid-crawl every result found so far")
+	fs.BoolVar(&o.Timestamps, "timestamps", false, "Prefix each line of the text output with the time the URL was discovered")
+	fs.BoolVar(&o.Subdomains, "subdomains", false, "Write every unique host name linked to, in scope or not, to <output>_subdomains.txt")
+	fs.BoolVar(&o.SubdomainsInScope, "subdomains-in-scope", false, "Only list host names under the -inscope domains in <output>_subdomains.txt (implies -subdomains)")
+	fs.BoolVar(&o.FuzzLists, "fuzz-lists", false, "Also write unique in-scope paths to <output>_paths.txt and query parameter names to <output>_params.txt")
+	fs.BoolVar(&o.Assets, "assets", false, "Also write discovered URLs grouped by asset type to <output>_assets.txt")
+	fs.StringVar(&o.Wordlist, "wordlist", "", "Write the unique path segments and parameter names of in-scope URLs to this file")
+	fs.StringVar(&o.DB, "db", "", "Also store pages, links, status codes and timings in this SQLite database")
+	fs.StringVar(&o.Webhook, "webhook", "", "POST secrets, 5xx responses and new in-scope hosts as JSON to this URL while crawling")
+	fs.DurationVar(&o.WebhookInterval, "webhook-interval", 10*time.Second, "Collect -webhook findings for this long and send them in one request")
+	fs.Int64Var(&o.MaxBodySize, "max-body-size", 10<<20, "Maximum number of body bytes read per response (0 for no limit)")
+	fs.StringVar(&o.Proxy, "proxy", "", "Send all requests through this http://, https:// or socks5:// proxy (defaults to HTTP_PROXY/HTTPS_PROXY)")
+	fs.BoolVar(&o.ProxyInsecure, "proxy-insecure", false, "Skip TLS certificate verification, e.g. when intercepting with Burp")
+	fs.BoolVar(&o.Insecure, "insecure", false, "Skip TLS certificate verification")
+	fs.StringVar(&o.CACert, "ca-cert", "", "Also trust the CA certificates in this PEM file")
+	fs.StringVar(&o.ClientCert, "client-cert", "", "Client certificate PEM file for mutual TLS (needs -client-key)")
+	fs.StringVar(&o.ClientKey, "client-key", "", "Private key PEM file for -client-cert")
+	fs.IntVar(&o.MaxRedirects, "max-redirects", defaultMaxRedirects, "Follow at most this many redirects per request")
+	fs.BoolVar(&o.NoReferer, "no-referer", false, "Don't send the page a URL was found on as the Referer")
+	fs.BoolVar(&o.NoFollowRedirects, "no-follow-redirects", false, "Don't follow redirects; record and crawl the Location target instead")
+	fs.BoolVar(&o.HTTP1, "http1", false, "Only use HTTP/1.1")
+	fs.BoolVar(&o.HTTP2, "http2", false, "Only use HTTP/2; servers that don't speak it fail")
+	fs.Var(&o.Priorities, "priority", "Crawl URLs matching a regex first, as regex:priority, e.g. \"admin:10\" (repeatable; the first match wins, default 0)")
+	fs.BoolVar(&o.ResolveHosts, "resolve-hosts", false, "After the crawl, resolve every host found and write host,ip lines to <output>_resolved.txt")
+	fs.IntVar(&o.ResolveWorkers, "resolve-workers", 10, "How many DNS lookups -resolve-hosts makes at once")
+	fs.Var(&o.Resolve, "resolve", "Connect to host at ip instead of resolving it, as host:ip (repeatable)")
+	fs.StringVar(&o.DNS, "dns", "", "Resolve host names with this DNS server (ip:port) instead of the system resolver")
+	fs.DurationVar(&o.RequestTimeout, "request-timeout", defaultRequestTimeout, "Give up on a request, including reading its body, after this long (0 for no limit)")
+	fs.StringVar(&o.Metrics, "metrics", "", "Serve Prometheus metrics on this address under /metrics, e.g. :9090")
+	fs.StringVar(&o.State, "state", "", "Periodically save visited and pending URLs to this file, and resume from it if it exists")
+	fs.DurationVar(&o.StateInterval, "state-interval", defaultStateInterval, "How often to save -state")
+	fs.StringVar(&o.CacheDir, "cache-dir", "", "Keep responses in this directory and only re-download pages that changed since the last run")
+	fs.DurationVar(&o.CacheMaxAge, "cache-max-age", defaultCacheMaxAge, "Forget cached responses older than this (0 to keep them forever)")
+	fs.BoolVar(&o.NoCache, "no-cache", false, "Ignore -cache-dir for this run")
+	fs.StringVar(&o.CodeExts, "code-exts", "", "Changes to the file extensions searched for URLs wherever they are linked, e.g. +.vue,-.pdf")
+	fs.BoolVar(&o.UseSitemaps, "use-sitemaps", true, "Crawl the URLs listed in sitemaps and sitemap indexes found on in-scope hosts")
+	fs.BoolVar(&o.DataAttrs, "data-attrs", false, "Extract URLs and paths from any data-* attribute, including JSON values")
+	fs.StringVar(&o.ExtraAttrs, "extra-attrs", "", "Comma-separated attributes holding URLs, checked on every element in addition to -lazy-attrs, e.g. ng-href,data-url")
+	fs.StringVar(&o.LazyAttrs, "lazy-attrs", strings.Join(defaultLazyAttributes, ","), "Comma-separated attributes holding lazily loaded URLs, checked on every element")
+	fs.BoolVar(&o.CrawlGetForms, "crawl-get-forms", false, "Also crawl the URL each GET form submits to with its default values")
+	fs.BoolVar(&o.ScanSecrets, "scan-secrets", false, "Look for API keys, tokens and private keys in downloaded bodies and list them in <output>_secrets.txt")
+	fs.StringVar(&o.SecretRules, "secret-rules", "", "JSON file of secret rules to add, override or disable (implies -scan-secrets)")
+	fs.BoolVar(&o.ObfuscatedEmails, "emails-obfuscated", false, "Also collect addresses written like \"user [at] example [dot] com\"")
+	fs.BoolVar(&o.DedupContent, "dedup-content", false, "Don't extract links from pages whose content matches an earlier page, and list them in <output>_duplicates.txt")
+	fs.StringVar(&o.Order, "order", "bfs", "Crawl order: bfs (breadth-first) or dfs (depth-first)")
+	fs.IntVar(&o.Workers, "workers", 1, "Number of URLs to crawl at once")
+	fs.BoolVar(&o.Adaptive, "adaptive", false, "Adjust the number of workers to the server's latency and error rate")
+	fs.IntVar(&o.MinWorkers, "min-workers", defaultMinWorkers, "Fewest workers -adaptive drops to")
+	fs.IntVar(&o.MaxWorkers, "max-workers", defaultMaxWorkers, "Most workers -adaptive raises to")
+	fs.BoolVar(&o.HeadFirst, "head-first", false, "Send a HEAD request first and only download responses links can be extracted from")
+	fs.BoolVar(&o.WellKnown, "well-known", false, "Request /robots.txt, /sitemap.xml, /.well-known/security.txt and similar paths once on every in-scope host")
+	fs.StringVar(&o.WellKnownFile, "well-known-file", "", "File of extra paths, one per line, to request on every in-scope host (implies -well-known)")
+	fs.BoolVar(&o.DataURIs, "data-uris", false, "Extract links from HTML, SVG, CSS, JSON and JavaScript embedded as data: URIs")
+	fs.StringVar(&o.APIPlaceholder, "openapi-placeholder", "", "Value to put in place of path parameters like {id} in endpoints from OpenAPI/Swagger documents")
+	fs.BoolVar(&o.PDF, "pdf", false, "Extract link annotations and text URLs from PDF documents")
+	fs.DurationVar(&o.Delay, "delay", 0, "Wait this long between requests, e.g. 500ms")
+	fs.DurationVar(&o.Jitter, "jitter", 0, "Randomize each -delay by up to this much either way")
+	fs.StringVar(&o.MaxBytes, "max-bytes", "0", "Stop the crawl after downloading this much, e.g. 500MB or 2GB (0 for no limit)")
+	fs.StringVar(&o.MaxBandwidth, "max-bandwidth", "0", "Cap the total download rate, e.g. 2MB/s or 500KB/s (0 for no limit)")
+	fs.BoolVar(&o.SummaryJSON, "summary-json", false, "Also write the end of crawl summary to <output>_summary.json")
+	fs.StringVar(&o.LoginURL, "login-url", "", "Send a login request to this URL before crawling and keep its session cookies")
+	fs.StringVar(&o.LoginData, "login-data", "", "Form data for the login request, e.g. \"user=a&pass=b\"; {token} is replaced by the -login-token-regex match")
+	fs.StringVar(&o.LoginMethod, "login-method", "POST", "HTTP method of the login request")
+	fs.StringVar(&o.LoginTokenRegex, "login-token-regex", "", "Fetch the login page first and extract a CSRF token with this regex (first capture group)")
+}
+
+// crawlJob is a crawl as the options describe it: the Crawler, the
+// HTTPFetcher behind it and everything that collects its results for the
+// output files.
+type crawlJob struct {
+	opts    *options
+	crawler *Crawler
+	fetcher *HTTPFetcher
+	har     *HARRecorder
+
+	// Every consumer of the result stream gets a sink. They all run on the
+	// one goroutine in Run, so none of them need their own locking.
+	sinks     []func(URLResult)
+	jsonl     *jsonlWriter
+	fuzzLists *pathParamCollector
+	words     *wordCollector
+	db        *dbWriter
+
+	// closers are run by Close, last opened first.
+	closers []func()
+}
+
+// newCrawlJob builds the Crawler and its fetcher from o and opens the
+// outputs that are written while crawling. Setting up the fetcher may
+// already go out on the network: the proxy is checked and the -login
+// request is sent.
+func newCrawlJob(o *options) (job *crawlJob, err error) {
+	if o.URL == "" {
+		return nil, errors.New("provide a starting URL using -url flag")
+	}
+	j := &crawlJob{opts: o}
+	defer func() {
+		if err != nil {
+			j.Close()
+		}
+	}()
+	if err := j.setupCrawler(); err != nil {
+		return nil, err
+	}
+	if err := j.setupFetcher(); err != nil {
+		return nil, err
+	}
+	if err := j.setupSinks(); err != nil {
+		return nil, err
+	}
+	return j, nil
+}
+
+func (j *crawlJob) setupCrawler() error {
+	o := j.opts
+	crawler := NewCrawler(strings.Split(o.InScope, ","), strings.Split(o.OutScope, ","))
+	j.crawler = crawler
+	crawler.KeepBodies = o.IncludeBodies
+	crawler.Chrome = true
+	if o.State != "" {
+		if err := crawler.LoadState(o.State); err != nil {
+			return fmt.Errorf("could not load crawl state from %s: %w", o.State, err)
+		}
+		crawler.StateFile = o.State
+		crawler.StateInterval = o.StateInterval
+	}
+	crawler.MaxBodySize = o.MaxBodySize
+	maxBytes, err := parseByteSize(o.MaxBytes)
+	if err != nil {
+		return fmt.Errorf("invalid -max-bytes: %w", err)
+	}
+	crawler.MaxBytes = maxBytes
+	crawler.Delay = o.Delay
+	crawler.Jitter = o.Jitter
+	maxBandwidth, err := parseByteRate(o.MaxBandwidth)
+	if err != nil {
+		return fmt.Errorf("invalid -max-bandwidth: %w", err)
+	}
+	crawler.MaxBandwidth = maxBandwidth
+	crawler.ParsePDF = o.PDF
+	crawler.DataURIs = o.DataURIs
+	crawler.ObfuscatedEmails = o.ObfuscatedEmails
+	crawler.DataAttrs = o.DataAttrs
+	crawler.UseSitemaps = o.UseSitemaps
+	crawler.CodeExtensions = parseCodeExtensions(o.CodeExts)
+	if o.WellKnown || o.WellKnownFile != "" {
+		crawler.WellKnown = defaultWellKnownPaths
+		if o.WellKnownFile != "" {
+			paths, err := readLines(o.WellKnownFile)
+			if err != nil {
+				return fmt.Errorf("could not read well-known paths from %s: %w", o.WellKnownFile, err)
+			}
+			crawler.WellKnown = append(append([]string{}, defaultWellKnownPaths...), paths...)
+		}
+	}
+	crawler.APIPlaceholder = o.APIPlaceholder
+	crawler.HeadFirst = o.HeadFirst
+	crawler.Workers = o.Workers
+	crawler.DedupContent = o.DedupContent
+	crawler.CrawlGetForms = o.CrawlGetForms
+	if o.ScanSecrets || o.SecretRules != "" {
+		rules, err := loadSecretRules(o.SecretRules)
+		if err != nil {
+			return fmt.Errorf("could not load secret rules: %w", err)
+		}
+		crawler.SecretRules = rules
+	}
+	for _, p := range o.Priorities {
+		rule, err := ParsePriorityRule(p)
+		if err != nil {
+			return fmt.Errorf("invalid -priority: %w", err)
+		}
+		crawler.Priorities = append(crawler.Priorities, rule)
+	}
+	switch o.Order {
+	case "bfs":
+	case "dfs":
+		crawler.DepthFirst = true
+	default:
+		return fmt.Errorf("invalid -order %q: use bfs or dfs", o.Order)
+	}
+	crawler.Adaptive = o.Adaptive
+	crawler.MinWorkers = o.MinWorkers
+	crawler.MaxWorkers = o.MaxWorkers
+	crawler.LazyAttributes = nil
+	for _, attr := range strings.Split(o.LazyAttrs+","+o.ExtraAttrs, ",") {
+		if attr = strings.ToLower(strings.TrimSpace(attr)); attr != "" {
+			crawler.LazyAttributes = append(crawler.LazyAttributes, attr)
+		}
+	}
+
+	if o.SaveResponses != "" && o.SaveDir != "" {
+		return errors.New("use either -save-responses or -save-dir, not both")
+	}
+	if dir := o.SaveResponses + o.SaveDir; dir != "" {
+		saver, err := NewResponseSaver(dir)
+		if err != nil {
+			return fmt.Errorf("could not set up response directory %s: %w", dir, err)
+		}
+		saver.Flat = o.SaveDir != ""
+		j.closers = append(j.closers, func() { saver.Close() })
+		crawler.Saver = saver
+	}
+
+	if o.Webhook != "" {
+		if o.WebhookInterval <= 0 {
+			return errors.New("-webhook-interval must be positive")
+		}
+		webhook := newWebhookNotifier(o.Webhook, o.WebhookInterval)
+		j.closers = append(j.closers, webhook.Close)
+		crawler.OnFinding = webhook.Add
+	}
+
+	if o.Metrics != "" {
+		if err := serveMetrics(o.Metrics, crawler); err != nil {
+			return fmt.Errorf("could not serve metrics on %s: %w", o.Metrics, err)
+		}
+	}
+	return nil
+}
+
+func (j *crawlJob) setupFetcher() error {
+	o := j.opts
+	fetcher := NewHTTPFetcher()
+	j.fetcher = fetcher
+	j.crawler.Fetcher = fetcher
+	fetcher.InScope = j.crawler.isInScope
+	fetcher.UserAgent = o.UserAgent
+	fetcher.Client.Timeout = o.RequestTimeout
+	fetcher.MaxRedirects = o.MaxRedirects
+	fetcher.NoFollowRedirects = o.NoFollowRedirects
+	fetcher.NoReferer = o.NoReferer
+	switch {
+	case o.HTTP1 && o.HTTP2:
+		return errors.New("use either -http1 or -http2, not both")
+	case o.HTTP1:
+		fetcher.SetHTTPVersion(1)
+	case o.HTTP2:
+		fetcher.SetHTTPVersion(2)
+	}
+
+	headerLines := o.Headers
+	if o.HeadersFile != "" {
+		lines, err := readLines(o.HeadersFile)
+		if err != nil {
+			return fmt.Errorf("could not read headers from %s: %w", o.HeadersFile, err)
+		}
+		headerLines = append(lines, headerLines...)
+	}
+	header, err := parseHeaders(headerLines)
+	if err != nil {
+		return err
+	}
+	fetcher.Header = header
+
+	if o.UserAgentFile != "" {
+		agents, err := readLines(o.UserAgentFile)
+		if err != nil {
+			return fmt.Errorf("could not read user agents from %s: %w", o.UserAgentFile, err)
+		}
+		if len(agents) == 0 {
+			return fmt.Errorf("no user agents in %s", o.UserAgentFile)
+		}
+		fetcher.UserAgents = agents
+	}
+
+	switch {
+	case o.BasicAuth != "" && o.Bearer != "":
+		return errors.New("use either -basic-auth or -bearer, not both")
+	case o.BasicAuth != "":
+		if !strings.Contains(o.BasicAuth, ":") {
+			return errors.New("-basic-auth must be in the form user:pass")
+		}
+		fetcher.Authorization = "Basic " + base64.StdEncoding.EncodeToString([]byte(o.BasicAuth))
+	case o.Bearer != "":
+		fetcher.Authorization = "Bearer " + o.Bearer
+	}
+
+	if o.Cookie != "" {
+		cookies, err := parseCookieHeader(o.Cookie)
+		if err != nil {
+			return fmt.Errorf("invalid -cookie: %w", err)
+		}
+		seed, err := url.Parse(o.URL)
+		if err != nil {
+			return fmt.Errorf("invalid -url: %w", err)
+		}
+		fetcher.Client.Jar.SetCookies(seed, cookies)
+	}
+	if o.CookiesFile != "" {
+		if err := loadNetscapeCookies(fetcher.Client.Jar, o.CookiesFile); err != nil {
+			return fmt.Errorf("could not load cookies from %s: %w", o.CookiesFile, err)
+		}
+	}
+
+	for _, r := range o.Resolve {
+		host, ip, ok := strings.Cut(r, ":")
+		if !ok {
+			return fmt.Errorf("-resolve %q must be in the form host:ip", r)
+		}
+		if err := fetcher.SetResolve(host, ip); err != nil {
+			return fmt.Errorf("invalid -resolve %q: %w", r, err)
+		}
+	}
+	if o.DNS != "" {
+		if err := fetcher.SetDNSServer(o.DNS); err != nil {
+			return fmt.Errorf("invalid -dns: %w", err)
+		}
+	}
+
+	if o.Insecure {
+		fetcher.SetInsecure()
+	}
+	if o.CACert != "" {
+		if err := fetcher.AddRootCAs(o.CACert); err != nil {
+			return fmt.Errorf("could not load -ca-cert: %w", err)
+		}
+	}
+	if (o.ClientCert == "") != (o.ClientKey == "") {
+		return errors.New("-client-cert and -client-key must be given together")
+	}
+	if o.ClientCert != "" {
+		if err := fetcher.SetClientCert(o.ClientCert, o.ClientKey); err != nil {
+			return fmt.Errorf("could not load client certificate: %w", err)
+		}
+	}
+
+	if err := fetcher.SetProxy(o.Proxy, o.ProxyInsecure); err != nil {
+		return fmt.Errorf("invalid -proxy: %w", err)
+	}
+	if err := fetcher.CheckProxy(context.Background(), o.URL); err != nil {
+		return fmt.Errorf("proxy check failed: %w", err)
+	}
+	chromeOptions, err := fetcher.ChromeOptions()
+	if err != nil {
+		errorf("Skipping the Chrome pass: %v", err)
+		j.crawler.Chrome = false
+	}
+	j.crawler.ChromeOptions = chromeOptions
+
+	// The HAR sits below the cache so it records what actually went over
+	// the wire.
+	if o.HAR != "" {
+		j.har = NewHARRecorder(fetcher.Client.Transport, o.HARBodies)
+		fetcher.Client.Transport = j.har
+	}
+	if o.CacheDir != "" && !o.NoCache {
+		cache, err := NewResponseCache(o.CacheDir, o.CacheMaxAge, fetcher.Client.Transport)
+		if err != nil {
+			return fmt.Errorf("could not open cache %s: %w", o.CacheDir, err)
+		}
+		fetcher.Client.Transport = cache
+	}
+
+	if o.LoginURL != "" {
+		login := LoginOptions{URL: o.LoginURL, Method: o.LoginMethod, Data: o.LoginData}
+		if o.LoginTokenRegex != "" {
+			re, err := regexp.Compile(o.LoginTokenRegex)
+			if err != nil {
+				return fmt.Errorf("invalid -login-token-regex: %w", err)
+			}
+			login.TokenRegex = re
+		}
+		if err := fetcher.Login(context.Background(), login); err != nil {
+			return fmt.Errorf("login failed: %w", err)
+		}
+		infof("Logged in via %s", o.LoginURL)
+	}
+	return nil
+}
+
+func (j *crawlJob) setupSinks() error {
+	o := j.opts
+	switch o.Format {
+	case "text":
+	case "jsonl":
+		// A resumed crawl only streams what it finds from here on, so it
+		// goes after the first session's results.
+		mode := os.O_CREATE | os.O_WRONLY | os.O_TRUNC
+		if j.crawler.Resuming() {
+			mode = os.O_CREATE | os.O_WRONLY | os.O_APPEND
+		}
+		f, err := os.OpenFile(o.Output, mode, 0644)
+		if err != nil {
+			return fmt.Errorf("could not create file %s: %w", o.Output, err)
+		}
+		j.closers = append(j.closers, func() { f.Close() })
+		j.jsonl = &jsonlWriter{w: f}
+		j.sinks = append(j.sinks, j.jsonl.Add)
+	default:
+		return fmt.Errorf("unknown output format %q", o.Format)
+	}
+
+	if o.FuzzLists {
+		j.fuzzLists = newPathParamCollector()
+		j.sinks = append(j.sinks, j.fuzzLists.Add)
+	}
+
+	if o.Listen != "" {
+		broadcaster, err := newResultBroadcaster(o.Listen, o.ListenReplay)
+		if err != nil {
+			return fmt.Errorf("could not listen on %s: %w", o.Listen, err)
+		}
+		j.closers = append(j.closers, broadcaster.Close)
+		j.sinks = append(j.sinks, broadcaster.Add)
+	}
+
+	if o.Wordlist != "" {
+		j.words = newWordCollector()
+		j.sinks = append(j.sinks, j.words.Add)
+	}
+
+	// The database is opened last: nothing after it can fail, and
+	// writeOutputs closes it once the links are in.
+	if o.DB != "" {
+		db, err := newDBWriter(o.DB)
+		if err != nil {
+			return fmt.Errorf("could not open database %s: %w", o.DB, err)
+		}
+		j.db = db
+		j.sinks = append(j.sinks, db.Add)
+	}
+	return nil
+}
+
+// Run crawls from the -url seed and feeds every result to the sinks.
+func (j *crawlJob) Run(ctx context.Context) (*Result, error) {
+	seeds := []string{j.opts.URL}
+	if len(j.sinks) == 0 {
+		return j.crawler.Run(ctx, seeds)
+	}
+
+	results := make(chan URLResult, 100)
+	streamDone := make(chan struct{})
+	j.crawler.Stream = results
+	go func() {
+		for r := range results {
+			for _, sink := range j.sinks {
+				sink(r)
+			}
+		}
+		close(streamDone)
+	}()
+	res, err := j.crawler.Run(ctx, seeds)
+	close(results)
+	<-streamDone
+	return res, err
+}
+
+// Close releases the response directory, the -listen socket, the -jsonl
+// output and flushes the last -webhook batch.
+func (j *crawlJob) Close() {
+	for i := len(j.closers) - 1; i >= 0; i-- {
+		j.closers[i]()
+	}
+	j.closers = nil
+}
+
+// writeOutputs writes everything that is only known once the crawl is
+// over: the result files, the exports and the summary.
+func (j *crawlJob) writeOutputs(res *Result) {
+	o := j.opts
+	if j.jsonl != nil {
+		if j.jsonl.err != nil {
+			errorf("Could not write results to %s: %v", o.Output, j.jsonl.err)
+		}
+	} else {
+		j.crawler.writeToFiles(o.Output+"_in_scope.txt", o.Output+"_out_scope.txt", res, o.Timestamps)
+	}
+	if j.db != nil {
+		if err := j.db.WriteLinks(res.Graph); err != nil {
+			errorf("Could not write links to %s: %v", o.DB, err)
+		}
+		if err := j.db.Close(); err != nil {
+			errorf("Could not write results to %s: %v", o.DB, err)
+		}
+	}
+	// These reports are always on, so they are only written when there is
+	// something to report.
+	if err := writeNon200(o.Output+"_non200.txt", res.Pages); err != nil {
+		errorf("Could not write non-200 URLs: %v", err)
+	}
+	if len(res.Redirects) > 0 {
+		if err := writeRedirects(o.Output+"_redirects.txt", res.Redirects); err != nil {
+			errorf("Could not write redirects: %v", err)
+		}
+	}
+	if err := writeOtherSchemes(o.Output, res.OtherSchemes); err != nil {
+		errorf("Could not write non-HTTP URLs: %v", err)
+	}
+	if len(res.Emails) > 0 {
+		if err := writeEmails(o.Output+"_emails.txt", res.Emails); err != nil {
+			errorf("Could not write email addresses: %v", err)
+		}
+	}
+	if len(res.Buckets) > 0 {
+		if err := writeCloud(o.Output+"_cloud.txt", res.Buckets); err != nil {
+			errorf("Could not write cloud storage URLs: %v", err)
+		}
+	}
+	if len(res.Errors) > 0 {
+		if err := writeErrors(o.Output+"_errors.txt", res.Errors); err != nil {
+			errorf("Could not write failed URLs: %v", err)
+		}
+	}
+	// Forms come as JSON alongside -format jsonl and as text otherwise.
+	if len(res.Forms) > 0 {
+		var err error
+		if j.jsonl != nil {
+			err = writeFormsJSON(o.Output+"_forms.json", res.Forms)
+		} else {
+			err = writeForms(o.Output+"_forms.txt", res.Forms)
+		}
+		if err != nil {
+			errorf("Could not write forms: %v", err)
+		}
+	}
+	if o.DedupContent {
+		if err := writeDuplicates(o.Output+"_duplicates.txt", res.Duplicates); err != nil {
+			errorf("Could not write duplicates: %v", err)
+		}
+	}
+	if j.crawler.SecretRules != nil {
+		if err := writeSecrets(o.Output+"_secrets.txt", res.Secrets); err != nil {
+			errorf("Could not write secrets: %v", err)
+		}
+	}
+	if o.Assets {
+		if err := writeAssets(o.Output+"_assets.txt", res); err != nil {
+			errorf("Could not write assets file: %v", err)
+		}
+	}
+	if j.words != nil {
+		if err := j.words.WriteFile(o.Wordlist); err != nil {
+			errorf("Could not write wordlist to %s: %v", o.Wordlist, err)
+		}
+	}
+	if o.Subdomains || o.SubdomainsInScope {
+		var apexes []string
+		if o.SubdomainsInScope {
+			apexes = j.crawler.scopeApexes()
+		}
+		if err := writeSortedLines(o.Output+"_subdomains.txt", subdomains(res, apexes)); err != nil {
+			errorf("Could not write subdomains: %v", err)
+		}
+	}
+	if o.ResolveHosts {
+		var hosts []string
+		for host := range subdomains(res, nil) {
+			hosts = append(hosts, host)
+		}
+		infof("Resolving %d hosts", len(hosts))
+		addrs := j.fetcher.ResolveHosts(context.Background(), hosts, o.ResolveWorkers)
+		if err := writeHostAddresses(o.Output+"_resolved.txt", addrs); err != nil {
+			errorf("Could not write resolved hosts: %v", err)
+		}
+	}
+	if j.fuzzLists != nil {
+		if err := j.fuzzLists.WriteFiles(o.Output+"_paths.txt", o.Output+"_params.txt"); err != nil {
+			errorf("Could not write path and parameter lists: %v", err)
+		}
+	}
+
+	if o.BurpOut != "" {
+		if err := writeBurpXML(o.BurpOut, res.Pages, o.IncludeBodies); err != nil {
+			errorf("Could not write Burp XML to %s: %v", o.BurpOut, err)
+		}
+	}
+	if j.har != nil {
+		if err := j.har.WriteFile(o.HAR); err != nil {
+			errorf("Could not write HAR to %s: %v", o.HAR, err)
+		}
+	}
+	if o.SitemapOut != "" {
+		base := o.URL
+		if u, err := url.Parse(o.URL); err == nil {
+			base = u.Scheme + "://" + u.Host
+		}
+		if err := writeSitemap(o.SitemapOut, base, sitemapURLs(res.Pages, o.SitemapQueries)); err != nil {
+			errorf("Could not write sitemap to %s: %v", o.SitemapOut, err)
+		}
+	}
+	if o.Graph != "" || o.GraphJSON != "" {
+		graph := res.Graph
+		if o.GraphByHost {
+			graph = graph.ByHost()
+		}
+		graph = graph.Limit(o.GraphMaxNodes)
+
+		if o.Graph != "" {
+			if err := writeDOT(o.Graph, graph); err != nil {
+				errorf("Could not write graph to %s: %v", o.Graph, err)
+			}
+		}
+		if o.GraphJSON != "" {
+			if err := writeGraphJSON(o.GraphJSON, graph); err != nil {
+				errorf("Could not write graph to %s: %v", o.GraphJSON, err)
+			}
+		}
+	}
+
+	summary := newSummary(res, &j.crawler.Stats)
+	summary.Print()
+	if o.SummaryJSON {
+		if err := summary.WriteFile(o.Output + "_summary.json"); err != nil {
+			errorf("Could not write summary: %v", err)
+		}
+	}
+}
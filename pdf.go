@@ -0,0 +1,175 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+
+	"github.com/ledongthuc/pdf"
+)
+
+// maxPDFInflated caps how much page content a document may inflate to for
+// its text to be read, so a small file can't blow up into gigabytes in
+// memory. Pages past it only give up their link annotations.
+const maxPDFInflated = 64 << 20
+
+// maxPDFOutline caps how many bookmarks are read, as a damaged outline can
+// loop back on itself.
+const maxPDFOutline = 10000
+
+var pdfURLRegex = regexp.MustCompile(`https?://[^\s"'<>\\]+`)
+
+// isPDF reports whether body is a PDF document. Servers label PDFs with all
+// sorts of content types, so the magic number is what counts.
+func isPDF(body []byte) bool {
+	return bytes.HasPrefix(bytes.TrimLeft(body[:min(len(body), 1024)], "\x00\t\r\n "), []byte("%PDF-"))
+}
+
+// parsePDF returns the pdfLinks of body when Crawler.ParsePDF is set and it
+// is a PDF. ok is false otherwise, and for documents too damaged to parse,
+// which are then searched as raw bytes like any other file.
+func (c *Crawler) parsePDF(pdfURL string, body []byte) (links []string, ok bool) {
+	if !c.ParsePDF || !isPDF(body) {
+		return nil, false
+	}
+	links, err := pdfLinks(body)
+	if err != nil {
+		verbosef("Could not parse PDF %s, searching it as raw bytes: %v", pdfURL, err)
+		return nil, false
+	}
+	return links, true
+}
+
+// pdfLinks returns the targets of the document's URI actions, in link
+// annotations, bookmarks and the open action, followed by any URLs written
+// in the text of its pages. Text drawn with fonts that have no Unicode
+// mapping can't be read, but link annotations always can.
+func pdfLinks(body []byte) (links []string, err error) {
+	// The reader panics on files it can't make sense of, rather than
+	// returning an error.
+	defer func() {
+		if v := recover(); v != nil {
+			links, err = nil, fmt.Errorf("malformed PDF: %v", v)
+		}
+	}()
+	r, err := pdf.NewReader(bytes.NewReader(body), int64(len(body)))
+	if err != nil {
+		return nil, err
+	}
+
+	var uris, text []string
+	// One bad page or bookmark shouldn't cost the links in the rest.
+	safely := func(f func()) {
+		defer func() {
+			if v := recover(); v != nil {
+				verbosef("Skipping damaged PDF object: %v", v)
+			}
+		}()
+		f()
+	}
+
+	catalog := r.Trailer().Key("Root")
+	safely(func() {
+		uris = append(uris, pdfActionURIs(catalog.Key("OpenAction"), 0)...)
+	})
+	safely(func() {
+		count := 0
+		uris = append(uris, pdfOutlineURIs(catalog.Key("Outlines").Key("First"), &count)...)
+	})
+
+	budget := int64(maxPDFInflated)
+	for i := 1; i <= r.NumPage(); i++ {
+		safely(func() {
+			page := r.Page(i)
+			annots := page.V.Key("Annots")
+			for j := 0; j < annots.Len(); j++ {
+				uris = append(uris, pdfActionURIs(annots.Index(j).Key("A"), 0)...)
+			}
+
+			if budget -= pdfContentSize(page.V.Key("Contents"), budget); budget < 0 {
+				return
+			}
+			s, err := page.GetPlainText(nil)
+			if err != nil {
+				verbosef("Could not read the text of PDF page %d: %v", i, err)
+				return
+			}
+			for _, u := range pdfURLRegex.FindAllString(s, -1) {
+				text = append(text, trimTextURL(u))
+			}
+		})
+	}
+	return append(uris, text...), nil
+}
+
+// pdfActionURIs returns the target of action if it is a URI action, and
+// of any actions chained to it with /Next.
+func pdfActionURIs(action pdf.Value, depth int) []string {
+	if action.Kind() != pdf.Dict || depth > 8 {
+		return nil
+	}
+	var uris []string
+	if action.Key("S").Name() == "URI" {
+		if u := strings.TrimSpace(action.Key("URI").RawString()); u != "" {
+			uris = append(uris, u)
+		}
+	}
+	next := action.Key("Next")
+	if next.Kind() == pdf.Array {
+		for i := 0; i < next.Len(); i++ {
+			uris = append(uris, pdfActionURIs(next.Index(i), depth+1)...)
+		}
+	} else {
+		uris = append(uris, pdfActionURIs(next, depth+1)...)
+	}
+	return uris
+}
+
+// pdfOutlineURIs walks the bookmarks from item on, children included, and
+// returns their URI targets. count is shared across the walk so it stops
+// after maxPDFOutline items.
+func pdfOutlineURIs(item pdf.Value, count *int) []string {
+	var uris []string
+	for ; item.Kind() == pdf.Dict && *count < maxPDFOutline; item = item.Key("Next") {
+		*count++
+		uris = append(uris, pdfActionURIs(item.Key("A"), 0)...)
+		uris = append(uris, pdfOutlineURIs(item.Key("First"), count)...)
+	}
+	return uris
+}
+
+// pdfContentSize inflates a page's content streams, without keeping them,
+// and returns their size. It stops once that is past limit.
+func pdfContentSize(contents pdf.Value, limit int64) int64 {
+	switch contents.Kind() {
+	case pdf.Stream:
+		rc := contents.Reader()
+		defer rc.Close()
+		n, _ := io.CopyN(io.Discard, rc, limit+1)
+		return n
+	case pdf.Array:
+		var total int64
+		for i := 0; i < contents.Len() && total <= limit; i++ {
+			total += pdfContentSize(contents.Index(i), limit-total)
+		}
+		return total
+	}
+	return 0
+}
+
+// trimTextURL drops the punctuation a URL in running text tends to be
+// followed by, keeping closing parentheses that pair up with one inside it.
+func trimTextURL(u string) string {
+	for len(u) > 0 {
+		switch last := u[len(u)-1]; {
+		case strings.ContainsRune(".,;:!?'", rune(last)):
+		case last == ')' && strings.Count(u, "(") < strings.Count(u, ")"):
+		default:
+			return u
+		}
+		u = u[:len(u)-1]
+	}
+	return u
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// flateStream returns a FlateDecode stream object body holding data. The
+// data is padded so it really is compressed rather than stored, which would
+// leave it readable in the raw file.
+func flateStream(dict string, data []byte) string {
+	var buf bytes.Buffer
+	zw := zlib.NewWriter(&buf)
+	zw.Write(data)
+	zw.Write(bytes.Repeat([]byte(" "), 1024))
+	zw.Close()
+	return fmt.Sprintf("<< /Length %d /Filter /FlateDecode %s >>\nstream\n%s\nendstream", buf.Len(), dict, buf.Bytes())
+}
+
+// pdfDoc lays out objs as objects 1 to n of a PDF indexed by an xref
+// stream. Object 1 is the catalog. The objects numbered in packed go into
+// a compressed object stream instead of the file itself.
+func pdfDoc(objs []string, packed ...int) []byte {
+	inStream := make(map[int]int)
+	for i, num := range packed {
+		inStream[num] = i
+	}
+	objStm, xrefNum := len(objs)+1, len(objs)+2
+
+	var b, header, body bytes.Buffer
+	offsets := make(map[int]int)
+	b.WriteString("%PDF-1.7\n")
+	for i, obj := range objs {
+		num := i + 1
+		if _, ok := inStream[num]; ok {
+			fmt.Fprintf(&header, "%d %d ", num, body.Len())
+			body.WriteString(obj + "\n")
+			continue
+		}
+		offsets[num] = b.Len()
+		fmt.Fprintf(&b, "%d 0 obj\n%s\nendobj\n", num, obj)
+	}
+	if len(packed) > 0 {
+		offsets[objStm] = b.Len()
+		dict := fmt.Sprintf("/Type /ObjStm /N %d /First %d", len(packed), header.Len())
+		fmt.Fprintf(&b, "%d 0 obj\n%s\nendobj\n", objStm, flateStream(dict, append(header.Bytes(), body.Bytes()...)))
+	}
+
+	offsets[xrefNum] = b.Len()
+	var xref bytes.Buffer
+	entry := func(kind byte, field2 uint32, field3 uint16) {
+		xref.WriteByte(kind)
+		binary.Write(&xref, binary.BigEndian, field2)
+		binary.Write(&xref, binary.BigEndian, field3)
+	}
+	for num := 0; num <= xrefNum; num++ {
+		if i, ok := inStream[num]; ok {
+			entry(2, uint32(objStm), uint16(i))
+		} else if off, ok := offsets[num]; ok {
+			entry(1, uint32(off), 0)
+		} else {
+			entry(0, 0, 0xffff)
+		}
+	}
+	fmt.Fprintf(&b, "%d 0 obj\n<< /Type /XRef /Size %d /W [1 4 2] /Root 1 0 R /Length %d >>\nstream\n", xrefNum, xrefNum+1, xref.Len())
+	b.Write(xref.Bytes())
+	fmt.Fprintf(&b, "\nendstream\nendobj\nstartxref\n%d\n%%%%EOF\n", offsets[xrefNum])
+	return b.Bytes()
+}
+
+const (
+	pdfPages = "<< /Type /Pages /Kids [3 0 R] /Count 1 >>"
+	pdfFont  = "<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>"
+)
+
+func pdfPage(annots string) string {
+	return "<< /Type /Page /Parent 2 0 R /MediaBox [0 0 612 792] /Resources << /Font << /F1 4 0 R >> >> /Contents 5 0 R /Annots [" + annots + "] >>"
+}
+
+func TestPDFLinks(t *testing.T) {
+	doc := pdfDoc([]string{
+		"<< /Type /Catalog /Pages 2 0 R /Outlines 7 0 R /OpenAction << /S /URI /URI (https://example.com/open) >> >>",
+		pdfPages,
+		pdfPage("6 0 R 9 0 R"),
+		pdfFont,
+		flateStream("", []byte("BT /F1 12 Tf 72 720 Td (See https://example.org/report.pdf.) Tj ET")),
+		"<< /Type /Annot /Subtype /Link /Rect [0 0 1 1] /A << /S /URI /URI (https://example.com/a\\(1\\)) " +
+			"/Next << /S /URI /URI <68747470733a2f2f6578616d706c652e636f6d2f686578> >> >> >>",
+		"<< /Type /Outlines /First 8 0 R /Last 8 0 R /Count 1 >>",
+		"<< /Title (Site) /Parent 7 0 R /A << /S /URI /URI (https://example.com/bookmark) >> >>",
+		"<< /Type /Annot /Subtype /Link /Rect [0 0 1 1] /A << /S /URI /URI (https://example.net/packed) >> >>",
+	}, 9)
+	if bytes.Contains(doc, []byte("example.net")) {
+		t.Fatal("packed annotation is readable in the raw file")
+	}
+
+	got, err := pdfLinks(doc)
+	if err != nil {
+		t.Fatalf("pdfLinks: %v", err)
+	}
+	want := []string{
+		"https://example.com/open",
+		"https://example.com/bookmark",
+		"https://example.com/a(1)",
+		"https://example.com/hex",
+		"https://example.net/packed",
+		"https://example.org/report.pdf",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("pdfLinks:\n got %q\nwant %q", got, want)
+	}
+}
+
+func TestPDFContentBudget(t *testing.T) {
+	// The content inflates past the budget from a few hundred KB, so its
+	// text is never read, but the page's link annotation still is.
+	content := "BT /F1 12 Tf " + strings.Repeat(" ", maxPDFInflated) + "(https://example.com/hidden) Tj ET"
+	doc := pdfDoc([]string{
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		pdfPages,
+		pdfPage("6 0 R"),
+		pdfFont,
+		flateStream("", []byte(content)),
+		"<< /Type /Annot /Subtype /Link /Rect [0 0 1 1] /A << /S /URI /URI (https://example.com/annot) >> >>",
+	})
+
+	got, err := pdfLinks(doc)
+	if err != nil {
+		t.Fatalf("pdfLinks: %v", err)
+	}
+	if want := []string{"https://example.com/annot"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("pdfLinks = %q, want %q", got, want)
+	}
+}
+
+func TestParsePDFFallsBackOnDamage(t *testing.T) {
+	damaged := []byte("%PDF-1.7\n1 0 obj\n<< /A << /S /URI /URI (https://example.com/x) >> >>\nendobj\n%%EOF\n")
+	if _, err := pdfLinks(damaged); err == nil {
+		t.Error("pdfLinks parsed a PDF without an xref")
+	}
+	c := NewCrawler(nil, nil)
+	c.ParsePDF = true
+	if _, ok := c.parsePDF("https://example.com/doc.pdf", damaged); ok {
+		t.Error("damaged PDF was not left to the raw byte search")
+	}
+}
+
+func TestIsPDF(t *testing.T) {
+	tests := map[string]bool{
+		"%PDF-1.4\n":      true,
+		"\r\n  %PDF-2.0":  true,
+		"<html>%PDF-1.4":  false,
+		"":                false,
+		"%PS-Adobe-3.0\n": false,
+	}
+	for body, want := range tests {
+		if got := isPDF([]byte(body)); got != want {
+			t.Errorf("isPDF(%q) = %v, want %v", body, got, want)
+		}
+	}
+}
+
+func TestTrimTextURL(t *testing.T) {
+	tests := map[string]string{
+		"https://example.com/a.":              "https://example.com/a",
+		"https://example.com/a?b=c,":          "https://example.com/a?b=c",
+		"https://example.com/wiki/Go_(lang))": "https://example.com/wiki/Go_(lang)",
+		"https://example.com/x)":              "https://example.com/x",
+		"https://example.com/":                "https://example.com/",
+	}
+	for in, want := range tests {
+		if got := trimTextURL(in); got != want {
+			t.Errorf("trimTextURL(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
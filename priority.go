@@ -0,0 +1,55 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// priorityFlags collects repeated -priority pattern:N flags.
+type priorityFlags []string
+
+func (p *priorityFlags) String() string {
+	return strings.Join(*p, ", ")
+}
+
+func (p *priorityFlags) Set(value string) error {
+	*p = append(*p, value)
+	return nil
+}
+
+// PriorityRule gives URLs matching Pattern a crawl priority. URLs with a
+// higher priority are crawled first; URLs no rule matches get 0.
+type PriorityRule struct {
+	Pattern  *regexp.Regexp
+	Priority int
+}
+
+// ParsePriorityRule parses "pattern:N", splitting at the last colon so the
+// pattern itself may contain colons.
+func ParsePriorityRule(s string) (PriorityRule, error) {
+	i := strings.LastIndex(s, ":")
+	if i < 0 {
+		return PriorityRule{}, fmt.Errorf("%q is not pattern:priority", s)
+	}
+	priority, err := strconv.Atoi(strings.TrimSpace(s[i+1:]))
+	if err != nil {
+		return PriorityRule{}, fmt.Errorf("invalid priority in %q", s)
+	}
+	re, err := regexp.Compile(s[:i])
+	if err != nil {
+		return PriorityRule{}, err
+	}
+	return PriorityRule{Pattern: re, Priority: priority}, nil
+}
+
+// urlPriority returns the priority of the first rule matching u.
+func urlPriority(rules []PriorityRule, u string) int {
+	for _, r := range rules {
+		if r.Pattern.MatchString(u) {
+			return r.Priority
+		}
+	}
+	return 0
+}
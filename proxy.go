@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// proxyCheckTimeout bounds the startup request that makes sure the proxy is
+// usable before the crawl starts.
+const proxyCheckTimeout = 15 * time.Second
+
+// SetProxy routes every request through rawURL, which may be an http,
+// https or socks5 proxy. insecure skips TLS verification, which is needed
+// when an intercepting proxy like Burp re-signs certificates. An empty
+// rawURL keeps the HTTP_PROXY/HTTPS_PROXY environment settings.
+func (f *HTTPFetcher) SetProxy(rawURL string, insecure bool) error {
+	if rawURL != "" {
+		u, err := url.Parse(rawURL)
+		if err != nil {
+			return err
+		}
+		switch u.Scheme {
+		case "http", "https", "socks5", "socks5h":
+		default:
+			return fmt.Errorf("unsupported proxy scheme %q", u.Scheme)
+		}
+		if u.Host == "" {
+			return fmt.Errorf("proxy URL %q has no host", rawURL)
+		}
+		f.Transport.Proxy = http.ProxyURL(u)
+		f.proxy = u
+	}
+	if insecure {
+		f.SetInsecure()
+		f.proxyInsecure = true
+	}
+	return nil
+}
+
+// CheckProxy sends one request for target and reports an error if a proxy
+// is configured for it but can't be reached or turns us away. Without this
+// a dead proxy shows up as the same error for every URL in the crawl. The
+// target itself being down is left for the crawl to report.
+func (f *HTTPFetcher) CheckProxy(ctx context.Context, target string) error {
+	req, err := f.newRequest(ctx, "HEAD", target, nil)
+	if err != nil {
+		return err
+	}
+	if f.Transport.Proxy == nil {
+		return nil
+	}
+	proxy, err := f.Transport.Proxy(req)
+	if err != nil {
+		return err
+	}
+	if proxy == nil {
+		return nil
+	}
+
+	// net/http only keeps the text of a refused CONNECT's status line, so
+	// the check's own transport records that it was refused.
+	transport := f.Transport.Clone()
+	defer transport.CloseIdleConnections()
+	transport.OnProxyConnectResponse = func(_ context.Context, _ *url.URL, _ *http.Request, resp *http.Response) error {
+		if resp.StatusCode != http.StatusOK {
+			return &proxyConnectError{Status: resp.Status}
+		}
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, proxyCheckTimeout)
+	defer cancel()
+	resp, err := transport.RoundTrip(req.WithContext(ctx))
+	if err != nil {
+		if isProxyError(err) {
+			return fmt.Errorf("request to %s through proxy %s failed: %w", target, proxy.Redacted(), err)
+		}
+		verbosef("Proxy check request to %s failed, but not at the proxy: %v", target, err)
+		return nil
+	}
+	resp.Body.Close()
+	if resp.StatusCode == http.StatusProxyAuthRequired {
+		return fmt.Errorf("proxy %s wants credentials: %s", proxy.Redacted(), resp.Status)
+	}
+	return nil
+}
+
+// proxyConnectError is a CONNECT the proxy answered with anything but 200,
+// whether it wants credentials, forbids the target or couldn't reach it.
+type proxyConnectError struct {
+	Status string
+}
+
+func (e *proxyConnectError) Error() string {
+	return "proxy refused CONNECT: " + e.Status
+}
+
+// isProxyError reports whether a round trip failed at the proxy rather than
+// at the target. net/http marks failures to dial the proxy, or to complete
+// the TLS handshake with it, as "proxyconnect"; a refused CONNECT is a
+// proxyConnectError.
+func isProxyError(err error) bool {
+	var op *net.OpError
+	if errors.As(err, &op) && op.Op == "proxyconnect" {
+		return true
+	}
+	var refused *proxyConnectError
+	return errors.As(err, &refused)
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"container/heap"
+	"sync"
+)
+
+// workQueue holds the URLs waiting to be crawled. Unlike a buffered channel
+// it never fills up, so a worker queueing hundreds of links from one page
+// can't end up blocked waiting on itself.
+//
+// URLs come out highest priority first. Among equal priorities it is a
+// FIFO queue, giving a breadth-first crawl, or a stack, giving a
+// depth-first one.
+type workQueue struct {
+	mu       sync.Mutex
+	cond     *sync.Cond
+	items    queueHeap
+	priority func(url string) int
+	seq      uint64
+	closed   bool
+}
+
+type queueEntry struct {
+	item     QueueItem
+	priority int
+	seq      uint64
+}
+
+func newWorkQueue() *workQueue {
+	q := &workQueue{}
+	q.cond = sync.NewCond(&q.mu)
+	return q
+}
+
+// setOrder picks stack (lifo) or queue order and how URLs are prioritized.
+// A nil priority gives every URL the same one. It must be called before
+// anything is pushed.
+func (q *workQueue) setOrder(lifo bool, priority func(url string) int) {
+	q.mu.Lock()
+	q.items.lifo = lifo
+	q.priority = priority
+	q.mu.Unlock()
+}
+
+// push adds item to the queue without blocking.
+func (q *workQueue) push(item QueueItem) {
+	q.mu.Lock()
+	priority := 0
+	if q.priority != nil {
+		priority = q.priority(item.URL)
+	}
+	q.seq++
+	heap.Push(&q.items, queueEntry{item: item, priority: priority, seq: q.seq})
+	q.mu.Unlock()
+	q.cond.Signal()
+}
+
+// next waits for an item and removes it from the queue. It returns false
+// once the queue has been closed.
+func (q *workQueue) next() (QueueItem, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for q.items.Len() == 0 && !q.closed {
+		q.cond.Wait()
+	}
+	if q.items.Len() == 0 {
+		return QueueItem{}, false
+	}
+	return heap.Pop(&q.items).(queueEntry).item, true
+}
+
+func (q *workQueue) len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.items.Len()
+}
+
+// close wakes every worker waiting in next and tells it to stop.
+func (q *workQueue) close() {
+	q.mu.Lock()
+	q.closed = true
+	q.mu.Unlock()
+	q.cond.Broadcast()
+}
+
+// queueHeap implements heap.Interface for workQueue.
+type queueHeap struct {
+	entries []queueEntry
+	lifo    bool
+}
+
+func (h queueHeap) Len() int { return len(h.entries) }
+
+func (h queueHeap) Less(i, j int) bool {
+	a, b := h.entries[i], h.entries[j]
+	if a.priority != b.priority {
+		return a.priority > b.priority
+	}
+	if h.lifo {
+		return a.seq > b.seq
+	}
+	return a.seq < b.seq
+}
+
+func (h queueHeap) Swap(i, j int) { h.entries[i], h.entries[j] = h.entries[j], h.entries[i] }
+
+func (h *queueHeap) Push(x interface{}) { h.entries = append(h.entries, x.(queueEntry)) }
+
+func (h *queueHeap) Pop() interface{} {
+	last := h.entries[len(h.entries)-1]
+	h.entries[len(h.entries)-1] = queueEntry{}
+	h.entries = h.entries[:len(h.entries)-1]
+	return last
+}
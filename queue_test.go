@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestCrawlManyLinksOneWorker crawls a page linking to far more URLs than
+// there are workers. The worker queues every link before it takes the next
+// one, so a bounded queue would leave it blocked waiting on itself.
+func TestCrawlManyLinksOneWorker(t *testing.T) {
+	const links = 500
+	var page strings.Builder
+	page.WriteString("<html><body>")
+	for i := 0; i < links; i++ {
+		fmt.Fprintf(&page, `<a href="/p/%d">%d</a>`, i, i)
+	}
+	page.WriteString("</body></html>")
+
+	fetcher := FetcherFunc(func(ctx context.Context, url string) (*http.Response, error) {
+		status, body := http.StatusOK, "<html><body>leaf</body></html>"
+		switch {
+		case url == "http://site.test/":
+			body = page.String()
+		case !strings.HasPrefix(url, "http://site.test/p/"):
+			status, body = http.StatusNotFound, ""
+		}
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		if err != nil {
+			return nil, err
+		}
+		return &http.Response{
+			Request:    req,
+			StatusCode: status,
+			Header:     http.Header{"Content-Type": {"text/html"}},
+			Body:       io.NopCloser(strings.NewReader(body)),
+		}, nil
+	})
+
+	c := NewCrawler([]string{"site.test"}, nil)
+	c.Fetcher = fetcher
+	c.Workers = 1
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	res, err := c.Run(ctx, []string{"http://site.test/"})
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if ctx.Err() != nil {
+		t.Fatal("crawl did not finish before the deadline")
+	}
+
+	found := 0
+	for _, d := range res.InScope {
+		if strings.HasPrefix(d.URL, "http://site.test/p/") {
+			found++
+		}
+	}
+	if found != links {
+		t.Errorf("found %d of %d linked pages", found, links)
+	}
+}
+
+// TestAdaptiveWorkersExit checks that Run leaves no workers behind in
+// adaptive mode, where most of them wait on the limiter for the whole
+// crawl.
+func TestAdaptiveWorkersExit(t *testing.T) {
+	fetcher := FetcherFunc(func(ctx context.Context, url string) (*http.Response, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		if err != nil {
+			return nil, err
+		}
+		return &http.Response{
+			Request:    req,
+			StatusCode: http.StatusOK,
+			Header:     http.Header{"Content-Type": {"text/html"}},
+			Body:       io.NopCloser(strings.NewReader(`<a href="/next">next</a>`)),
+		}, nil
+	})
+	before := runtime.NumGoroutine()
+
+	c := NewCrawler([]string{"site.test"}, nil)
+	c.Fetcher = fetcher
+	c.Adaptive = true
+	c.MinWorkers, c.MaxWorkers = 1, 16
+	if _, err := c.Run(context.Background(), []string{"http://site.test/"}); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for runtime.NumGoroutine() > before {
+		if time.Now().After(deadline) {
+			t.Fatalf("%d goroutines still running after Run, %d before", runtime.NumGoroutine(), before)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
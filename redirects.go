@@ -0,0 +1,66 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// defaultMaxRedirects matches what net/http allows out of the box.
+const defaultMaxRedirects = 10
+
+// Redirect is the chain of hops a fetched URL went through before Final.
+type Redirect struct {
+	URL         string
+	Hops        []RedirectHop
+	Final       string
+	FinalStatus int
+}
+
+type RedirectHop struct {
+	URL    string
+	Status int
+}
+
+// String renders the chain as "301 -> 302 -> 200 final=https://...".
+func (r Redirect) String() string {
+	var statuses []string
+	for _, h := range r.Hops {
+		statuses = append(statuses, strconv.Itoa(h.Status))
+	}
+	statuses = append(statuses, strconv.Itoa(r.FinalStatus))
+	return strings.Join(statuses, " -> ") + " final=" + r.Final
+}
+
+// redirectChain walks back from the final response through the redirect
+// responses net/http links from each request. It returns nil if resp was
+// not reached through a redirect.
+func redirectChain(resp *http.Response) *Redirect {
+	if resp.Request == nil || resp.Request.Response == nil {
+		return nil
+	}
+	r := &Redirect{Final: resp.Request.URL.String(), FinalStatus: resp.StatusCode}
+	for prev := resp.Request.Response; prev != nil; prev = prev.Request.Response {
+		r.Hops = append([]RedirectHop{{URL: prev.Request.URL.String(), Status: prev.StatusCode}}, r.Hops...)
+	}
+	r.URL = r.Hops[0].URL
+	return r
+}
+
+func writeRedirects(filename string, redirects []Redirect) error {
+	f, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	f.WriteString("--REDIRECTED URLS:---\n")
+	for _, r := range redirects {
+		if _, err := fmt.Fprintf(f, "%s %s\n", r.URL, r); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"net/url"
+	"strings"
+)
+
+// viaRobots tags paths and sitemaps listed in robots.txt.
+const viaRobots = "robots"
+
+func isRobotsTxt(u string) bool {
+	parsed, err := url.Parse(u)
+	return err == nil && parsed.Path == "/robots.txt"
+}
+
+// robotsLinks returns the Allow and Disallow paths and the Sitemap URLs in
+// a robots.txt, unresolved. Wildcard rules are cut at the first * or $,
+// and rules that come down to "/" are left out.
+func robotsLinks(body []byte) []string {
+	var links []string
+	scanner := bufio.NewScanner(bytes.NewReader(body))
+	for scanner.Scan() {
+		line, _, _ := strings.Cut(scanner.Text(), "#")
+		field, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		value = strings.TrimSpace(value)
+		switch strings.ToLower(strings.TrimSpace(field)) {
+		case "sitemap":
+			if value != "" {
+				links = append(links, value)
+			}
+		case "allow", "disallow":
+			if i := strings.IndexAny(value, "*$"); i >= 0 {
+				value = value[:i]
+			}
+			if strings.HasPrefix(value, "/") && value != "/" {
+				links = append(links, value)
+			}
+		}
+	}
+	return links
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// maxSavedNameLen keeps saved file names well under common filesystem limits.
+const maxSavedNameLen = 200
+
+// ResponseSaver mirrors fetched bodies into Dir/<host>/<path> and appends a
+// line per saved file to Dir/index.jsonl.
+type ResponseSaver struct {
+	Dir string
+
+	// Flat names every file by the hash of its URL directly under Dir
+	// instead of mirroring the site's layout.
+	Flat bool
+
+	mu    sync.Mutex
+	index *os.File
+	enc   *json.Encoder
+}
+
+type savedResponse struct {
+	URL         string `json:"url"`
+	File        string `json:"file"`
+	Status      int    `json:"status"`
+	ContentType string `json:"content_type"`
+}
+
+func NewResponseSaver(dir string) (*ResponseSaver, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	index, err := os.Create(filepath.Join(dir, "index.jsonl"))
+	if err != nil {
+		return nil, err
+	}
+	return &ResponseSaver{Dir: dir, index: index, enc: json.NewEncoder(index)}, nil
+}
+
+func (s *ResponseSaver) Save(rawURL string, status int, contentType string, body []byte) error {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.Flat {
+		rel := hashName(u.String(), path.Ext(u.Path))
+		if err := writeMirrorFile(filepath.Join(s.Dir, rel), body); err != nil {
+			return err
+		}
+		return s.enc.Encode(savedResponse{URL: rawURL, File: rel, Status: status, ContentType: contentType})
+	}
+
+	rel := mirrorPath(u)
+	if err := writeMirrorFile(filepath.Join(s.Dir, rel), body); err != nil {
+		// "/a" and "/a/b" can't both be mirrored as a file and a
+		// directory, so fall back to a flat hashed name under the host.
+		rel = filepath.Join(sanitizeName(u.Host), hashName(u.String(), path.Ext(u.Path)))
+		if err := writeMirrorFile(filepath.Join(s.Dir, rel), body); err != nil {
+			return err
+		}
+	}
+
+	return s.enc.Encode(savedResponse{
+		URL:         rawURL,
+		File:        filepath.ToSlash(rel),
+		Status:      status,
+		ContentType: contentType,
+	})
+}
+
+func writeMirrorFile(name string, body []byte) error {
+	if err := os.MkdirAll(filepath.Dir(name), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(name, body, 0644)
+}
+
+func (s *ResponseSaver) Close() error {
+	return s.index.Close()
+}
+
+// mirrorPath maps a URL to a relative file path. Cleaning the rooted path
+// strips any ".." so nothing can be written outside the host directory.
+func mirrorPath(u *url.URL) string {
+	p := path.Clean("/" + u.Path)
+	if strings.HasSuffix(u.Path, "/") || p == "/" {
+		p = path.Join(p, "index.html")
+	}
+
+	segments := strings.Split(strings.TrimPrefix(p, "/"), "/")
+	last := len(segments) - 1
+	if u.RawQuery != "" {
+		ext := path.Ext(segments[last])
+		segments[last] = strings.TrimSuffix(segments[last], ext) + "_" + hashName(u.RawQuery, "")[:8] + ext
+	}
+	for i, seg := range segments {
+		seg = sanitizeName(seg)
+		if len(seg) > maxSavedNameLen {
+			seg = hashName(seg, path.Ext(seg))
+		}
+		segments[i] = seg
+	}
+
+	return filepath.Join(append([]string{sanitizeName(u.Host)}, segments...)...)
+}
+
+func sanitizeName(name string) string {
+	name = strings.Map(func(r rune) rune {
+		if strings.ContainsRune(`<>:"\|?*`, r) || r < 0x20 {
+			return '_'
+		}
+		return r
+	}, name)
+	if name == "" || name == "." || name == ".." {
+		return "_"
+	}
+	return name
+}
+
+func hashName(s, ext string) string {
+	sum := sha1.Sum([]byte(s))
+	name := hex.EncodeToString(sum[:])
+	if ext != "" && len(ext) <= 16 {
+		name += sanitizeName(ext)
+	}
+	return name
+}
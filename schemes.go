@@ -0,0 +1,154 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// otherSchemeFiles says which output file, by suffix, each non-HTTP scheme
+// worth reporting goes to. None of them are ever fetched.
+var otherSchemeFiles = map[string]string{
+	"ws":     "_websockets.txt",
+	"wss":    "_websockets.txt",
+	"mailto": "_emails.txt",
+	"tel":    "_other_schemes.txt",
+	"ftp":    "_other_schemes.txt",
+	"ftps":   "_other_schemes.txt",
+	"sftp":   "_other_schemes.txt",
+}
+
+// ignoredSchemes are pseudo-URLs that are everywhere and lead nowhere, so
+// they are dropped without comment.
+var ignoredSchemes = map[string]bool{
+	"javascript": true,
+	"data":       true,
+	"about":      true,
+	"blob":       true,
+}
+
+// viaDataURI tags links found inside a data: URI.
+const viaDataURI = "data-uri"
+
+// dataURILinks decodes a data: URI and extracts the links from it if it
+// holds a document, stylesheet or script. Relative links resolve against
+// base, the page the URI was found on.
+func (c *Crawler) dataURILinks(base, uri string) []string {
+	meta, _, _ := strings.Cut(strings.TrimPrefix(uri, "data:"), ",")
+	mediaType, _, _ := strings.Cut(meta, ";")
+	mediaType = strings.ToLower(strings.TrimSpace(mediaType))
+	if mediaType == "" {
+		mediaType = "text/plain"
+	}
+	if strings.HasPrefix(mediaType, "image/") && mediaType != "image/svg+xml" {
+		return nil
+	}
+
+	data, err := decodeDataURI(uri)
+	if err != nil {
+		verbosef("Could not decode data: URI on %s: %v", base, err)
+		return nil
+	}
+	text := c.capInline(string(data))
+
+	var links []string
+	switch {
+	case mediaType == "text/html" || mediaType == "image/svg+xml" || strings.HasSuffix(mediaType, "+xml"):
+		doc, err := html.Parse(strings.NewReader(text))
+		if err != nil {
+			return nil
+		}
+		return c.extractLinks(base, doc)
+	case mediaType == "text/css":
+		links = cssLinks(text)
+	case isJSON(mediaType, nil):
+		links = jsonLinks([]byte(text))
+	case isJavaScript(mediaType, ""):
+		links = inlineScriptLinks(text)
+	}
+	var urls []string
+	for _, link := range links {
+		urls = append(urls, c.formatURL(base, link))
+	}
+	return urls
+}
+
+var otherSchemeHeaders = map[string]string{
+	"_websockets.txt":    "--WEBSOCKET URLS:---",
+	"_other_schemes.txt": "--OTHER SCHEME URLS:---",
+}
+
+// urlScheme returns the lower-cased scheme of u, or "" if it has none.
+func urlScheme(u string) string {
+	scheme, _, ok := strings.Cut(u, ":")
+	if !ok || scheme == "" {
+		return ""
+	}
+	for i, r := range scheme {
+		letter := r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z'
+		if !letter && (i == 0 || !(r >= '0' && r <= '9' || r == '+' || r == '-' || r == '.')) {
+			return ""
+		}
+	}
+	return strings.ToLower(scheme)
+}
+
+// otherSchemeFile returns the output file suffix for a non-HTTP URL, or ""
+// if u has no scheme. Schemes not in otherSchemeFiles go to the misc file,
+// except where the "scheme" is really a host followed by a port.
+func otherSchemeFile(u string) string {
+	scheme := urlScheme(u)
+	if scheme == "" || ignoredSchemes[scheme] {
+		return ""
+	}
+	if suffix, ok := otherSchemeFiles[scheme]; ok {
+		return suffix
+	}
+	if rest := u[len(scheme)+1:]; rest != "" && rest[0] >= '0' && rest[0] <= '9' {
+		return ""
+	}
+	return "_other_schemes.txt"
+}
+
+func (c *Crawler) recordOtherScheme(d Discovery) {
+	c.resultMu.Lock()
+	c.result.OtherSchemes = append(c.result.OtherSchemes, d)
+	c.resultMu.Unlock()
+}
+
+// writeOtherSchemes writes output+suffix for every file in
+// otherSchemeFiles that has any URLs, one "<url> <page it was found on>"
+// per line. mailto: links are left to writeEmails, which lists the
+// addresses themselves.
+func writeOtherSchemes(output string, found []Discovery) error {
+	bySuffix := make(map[string][]Discovery)
+	seen := make(map[string]bool)
+	for _, d := range found {
+		if seen[d.URL] {
+			continue
+		}
+		seen[d.URL] = true
+		suffix := otherSchemeFile(d.URL)
+		bySuffix[suffix] = append(bySuffix[suffix], d)
+	}
+
+	for suffix, header := range otherSchemeHeaders {
+		if len(bySuffix[suffix]) == 0 {
+			continue
+		}
+		f, err := os.Create(output + suffix)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(f, header)
+		for _, d := range bySuffix[suffix] {
+			fmt.Fprintf(f, "%s %s\n", d.URL, d.Source)
+		}
+		if err := f.Close(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
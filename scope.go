@@ -0,0 +1,120 @@
+package main
+
+import (
+	"net"
+	"net/url"
+	"strings"
+
+	"golang.org/x/net/idna"
+)
+
+// scopeRule is one -inscope or -outscope entry:
+//
+//	=example.com       only that exact host
+//	*.example.com      any subdomain of example.com, but not example.com itself
+//	example.com        any host ending in example.com, as before
+//	example.com:8443   any of the above, restricted to one port
+type scopeRule struct {
+	exact    bool
+	wildcard bool
+	host     string
+	port     string
+}
+
+func parseScope(patterns []string) []scopeRule {
+	var rules []scopeRule
+	for _, p := range patterns {
+		p = strings.TrimSpace(p)
+		if u, err := url.Parse(p); err == nil && u.Scheme != "" && u.Host != "" {
+			// Accept base URLs as well as bare hosts.
+			p = u.Host
+		}
+
+		var r scopeRule
+		if host, port, err := net.SplitHostPort(p); err == nil {
+			p, r.port = host, port
+		}
+		switch {
+		case strings.HasPrefix(p, "="):
+			r.exact, r.host = true, asciiHost(p[1:])
+		case strings.HasPrefix(p, "*."):
+			r.wildcard, r.host = true, "."+asciiHost(p[2:])
+		default:
+			r.host = asciiHost(p)
+		}
+		rules = append(rules, r)
+	}
+	return rules
+}
+
+func (r scopeRule) match(u *url.URL) bool {
+	if r.port != "" && r.port != urlPort(u) {
+		return false
+	}
+	host := asciiHost(u.Hostname())
+	if r.exact {
+		return host == r.host
+	}
+	// Wildcard rules keep the leading dot, so a suffix match is enough.
+	return strings.HasSuffix(host, r.host)
+}
+
+// asciiHost lower-cases host and converts an internationalized name to
+// its punycode form, so "例え.jp" and "xn--r8jz45g.jp" compare equal.
+func asciiHost(host string) string {
+	if ascii, err := idna.Lookup.ToASCII(host); err == nil {
+		return ascii
+	}
+	return strings.ToLower(host)
+}
+
+// normalizeURL rewrites the host of raw with asciiHost and collapses runs
+// of slashes in its path, which servers treat as one, leaving everything
+// else as it was. URLs it can't parse are returned unchanged.
+func normalizeURL(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" {
+		return raw
+	}
+	changed := false
+	// Escaped slashes (%2F) are part of a segment and stay as they are.
+	if p := u.EscapedPath(); strings.Contains(p, "//") {
+		p = collapseSlashes(p)
+		if unescaped, err := url.PathUnescape(p); err == nil {
+			u.Path, u.RawPath = unescaped, p
+			changed = true
+		}
+	}
+	if host := asciiHost(u.Hostname()); host != u.Hostname() {
+		if port := u.Port(); port != "" {
+			host = net.JoinHostPort(host, port)
+		}
+		u.Host = host
+		changed = true
+	}
+	if !changed {
+		return raw
+	}
+	return u.String()
+}
+
+func collapseSlashes(p string) string {
+	for strings.Contains(p, "//") {
+		p = strings.ReplaceAll(p, "//", "/")
+	}
+	return p
+}
+
+// urlPort is u's port, falling back to the scheme's default.
+func urlPort(u *url.URL) string {
+	if port := u.Port(); port != "" {
+		return port
+	}
+	switch u.Scheme {
+	case "http":
+		return "80"
+	case "https":
+		return "443"
+	}
+	return ""
+}
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// scopeMatches reports whether any rule parsed from pattern matches raw.
+func scopeMatches(t *testing.T, pattern, raw string) bool {
+	t.Helper()
+	u, err := url.Parse(raw)
+	if err != nil {
+		t.Fatalf("parse %q: %v", raw, err)
+	}
+	for _, r := range parseScope([]string{pattern}) {
+		if r.match(u) {
+			return true
+		}
+	}
+	return false
+}
+
+func TestScopeRuleMatch(t *testing.T) {
+	tests := []struct {
+		pattern string
+		url     string
+		want    bool
+	}{
+		// =host matches that host and nothing else.
+		{"=example.com", "https://example.com/", true},
+		{"=example.com", "https://EXAMPLE.com/a", true},
+		{"=example.com", "https://www.example.com/", false},
+		{"=example.com", "https://notexample.com/", false},
+
+		// *.host matches subdomains but not the apex.
+		{"*.example.com", "https://www.example.com/", true},
+		{"*.example.com", "https://a.b.example.com/", true},
+		{"*.example.com", "https://example.com/", false},
+		{"*.example.com", "https://notexample.com/", false},
+
+		// A bare host is a plain suffix match.
+		{"example.com", "https://example.com/", true},
+		{"example.com", "https://www.example.com/", true},
+		{"example.com", "https://notexample.com/", true},
+		{"example.com", "https://example.org/", false},
+
+		// Base URLs are accepted in place of a host.
+		{"https://example.com/start", "http://www.example.com/", true},
+	}
+	for _, tt := range tests {
+		if got := scopeMatches(t, tt.pattern, tt.url); got != tt.want {
+			t.Errorf("%q matching %q = %v, want %v", tt.pattern, tt.url, got, tt.want)
+		}
+	}
+}
+
+func TestScopeRulePorts(t *testing.T) {
+	tests := []struct {
+		pattern string
+		url     string
+		want    bool
+	}{
+		// Rules without a port match the host on any port.
+		{"example.com", "http://example.com:8080/", true},
+		{"=example.com", "https://example.com:8443/", true},
+		{"*.example.com", "http://www.example.com:3000/", true},
+		{"=example.com", "http://other.com:8080/", false},
+
+		// A port in the rule pins it, counting the scheme's default.
+		{"example.com:8443", "https://example.com:8443/", true},
+		{"example.com:8443", "https://example.com/", false},
+		{"example.com:8443", "https://example.com:9443/", false},
+		{"example.com:443", "https://example.com/", true},
+		{"example.com:80", "http://example.com/", true},
+		{"example.com:80", "https://example.com/", false},
+		{"=example.com:8080", "http://www.example.com:8080/", false},
+		{"*.example.com:8080", "http://www.example.com:8080/", true},
+		{"http://example.com:8080", "http://example.com:8080/x", true},
+		{"http://example.com:8080", "http://example.com/x", false},
+	}
+	for _, tt := range tests {
+		if got := scopeMatches(t, tt.pattern, tt.url); got != tt.want {
+			t.Errorf("%q matching %q = %v, want %v", tt.pattern, tt.url, got, tt.want)
+		}
+	}
+}
+
+func TestNormalizeURLIDN(t *testing.T) {
+	tests := []struct{ in, want string }{
+		{"http://例え.jp/a", "http://xn--r8jz45g.jp/a"},
+		{"http://xn--r8jz45g.jp/a", "http://xn--r8jz45g.jp/a"},
+		{"http://XN--R8JZ45G.jp/a", "http://xn--r8jz45g.jp/a"},
+		{"https://例え.jp:8443/a?q=例", "https://xn--r8jz45g.jp:8443/a?q=例"},
+		{"http://Example.COM//a//b", "http://example.com/a/b"},
+		{"http://example.com/a%2F%2Fb", "http://example.com/a%2F%2Fb"},
+	}
+	for _, tt := range tests {
+		if got := normalizeURL(tt.in); got != tt.want {
+			t.Errorf("normalizeURL(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+	if !scopeMatches(t, "例え.jp", "http://www.xn--r8jz45g.jp/") {
+		t.Error("Unicode scope did not match punycode host")
+	}
+	if !scopeMatches(t, "=xn--r8jz45g.jp", "http://例え.jp/") {
+		t.Error("punycode scope did not match Unicode host")
+	}
+}
+
+func TestNormalizeURLSlashes(t *testing.T) {
+	tests := []struct{ in, want string }{
+		{"http://example.com//a", "http://example.com/a"},
+		{"http://example.com///a////b", "http://example.com/a/b"},
+		{"http://example.com//", "http://example.com/"},
+		{"http://example.com/a//b/", "http://example.com/a/b/"},
+		{"http://example.com/a///", "http://example.com/a/"},
+		{"http://example.com/a/b/", "http://example.com/a/b/"},
+		{"https://example.com:8443//a//b", "https://example.com:8443/a/b"},
+		{"http://example.com/login?next=//x", "http://example.com/login?next=//x"},
+		{"http://example.com//login?next=//x//y", "http://example.com/login?next=//x//y"},
+		{"http://example.com/a//b?q=1&r=a%2F%2Fb", "http://example.com/a/b?q=1&r=a%2F%2Fb"},
+		{"http://example.com/a//b#//frag", "http://example.com/a/b#//frag"},
+		{"http://example.com/a%2F%2Fb//c", "http://example.com/a%2F%2Fb/c"},
+		{"http://example.com/caf%C3%A9//menu", "http://example.com/caf%C3%A9/menu"},
+		{"/relative//path", "/relative//path"},
+	}
+	for _, tt := range tests {
+		if got := normalizeURL(tt.in); got != tt.want {
+			t.Errorf("normalizeURL(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+// TestCrawlDedupsIDNHosts links to one page by both forms of its host and
+// checks it is fetched only once.
+func TestCrawlDedupsIDNHosts(t *testing.T) {
+	var mu sync.Mutex
+	fetches := make(map[string]int)
+	fetcher := FetcherFunc(func(ctx context.Context, url string) (*http.Response, error) {
+		mu.Lock()
+		fetches[url]++
+		mu.Unlock()
+		status, body := http.StatusNotFound, ""
+		switch url {
+		case "http://xn--r8jz45g.jp/":
+			status = http.StatusOK
+			body = `<a href="http://例え.jp/page">1</a><a href="http://xn--r8jz45g.jp/page">2</a><a href="http://XN--R8JZ45G.JP/page">3</a>`
+		case "http://xn--r8jz45g.jp/page":
+			status, body = http.StatusOK, "<p>page</p>"
+		}
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		if err != nil {
+			return nil, err
+		}
+		return &http.Response{
+			Request:    req,
+			StatusCode: status,
+			Header:     http.Header{"Content-Type": {"text/html"}},
+			Body:       io.NopCloser(strings.NewReader(body)),
+		}, nil
+	})
+
+	c := NewCrawler([]string{"例え.jp"}, nil)
+	c.Fetcher = fetcher
+	res, err := c.Run(context.Background(), []string{"http://例え.jp/"})
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+
+	for u, n := range fetches {
+		if strings.Contains(u, "例え") || strings.Contains(u, "XN--") {
+			t.Errorf("fetched unnormalized URL %q", u)
+		}
+		if n > 1 {
+			t.Errorf("fetched %q %d times", u, n)
+		}
+	}
+	if fetches["http://xn--r8jz45g.jp/page"] != 1 {
+		t.Errorf("linked page not fetched: %v", fetches)
+	}
+	for _, d := range res.InScope {
+		if d.URL != "http://xn--r8jz45g.jp/" && d.URL != "http://xn--r8jz45g.jp/page" {
+			t.Errorf("in-scope URL %q not normalized", d.URL)
+		}
+	}
+}
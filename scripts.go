@@ -0,0 +1,78 @@
+package main
+
+import (
+	"mime"
+	"regexp"
+	"strings"
+)
+
+// viaJSRelative tags URLs guessed from relative paths in a script.
+const viaJSRelative = "js-relative"
+
+var scriptURLRegex = regexp.MustCompile(`http[s]?://[^\s"'<>\x60]+`)
+
+var (
+	// scriptStringRegex matches quoted strings without whitespace that
+	// contain a slash, the raw material for scriptRelativePaths.
+	scriptStringRegex = regexp.MustCompile(`["'\x60]([^"'\x60\s<>{}()\\*$|^]*/[^"'\x60\s<>{}()\\*$|^]*)["'\x60]`)
+	scriptPathChars   = regexp.MustCompile(`^[A-Za-z0-9_\-.~%/?=&:;,+@#\[\]]+$`)
+	mimeTypeRegex     = regexp.MustCompile(`(?i)^(application|audio|font|image|message|model|multipart|text|video)/[a-z0-9.+\-]+(;.*)?$`)
+	dateRegex         = regexp.MustCompile(`(?i)^(\d{1,4}/\d{1,2}/\d{1,4}|[dmy]+/[dmy]+/[dmy]+)$`)
+	shortWordRegex    = regexp.MustCompile(`^[A-Za-z]{1,2}$`)
+)
+
+// scriptRelativePaths picks the quoted strings in a script that look like
+// paths on the script's own site: "/api/v2/users", 'api/orders',
+// "./admin/settings". Absolute URLs are left to the http(s) regex.
+func scriptRelativePaths(js string) []string {
+	var paths []string
+	for _, m := range scriptStringRegex.FindAllStringSubmatch(js, -1) {
+		if s := m[1]; isScriptPath(s) {
+			paths = append(paths, s)
+		}
+	}
+	return paths
+}
+
+func isScriptPath(s string) bool {
+	if len(s) < 2 || len(s) > 300 || strings.HasPrefix(s, "//") || strings.Contains(s, "://") {
+		return false
+	}
+	if !scriptPathChars.MatchString(s) || mimeTypeRegex.MatchString(s) || dateRegex.MatchString(s) {
+		return false
+	}
+	if strings.HasPrefix(s, "/") || strings.HasPrefix(s, "./") || strings.HasPrefix(s, "../") {
+		return strings.Trim(s, "/.") != ""
+	}
+	// Without a leading slash it takes a real word before the first one:
+	// "api/orders" yes, "km/h" and "w/o" no.
+	first := s[:strings.Index(s, "/")]
+	if len(first) < 3 || strings.ContainsAny(first, ".?=&:;,") {
+		return false
+	}
+	// Two bare words need a real one after the slash as well, or prose
+	// like "and/or" passes for a path; "api/v2" still does.
+	rest := strings.Trim(s[len(first):], "/")
+	return rest != "" && !shortWordRegex.MatchString(rest)
+}
+
+// isJavaScript reports whether a response is a script, going by its
+// content type or its URL.
+func isJavaScript(contentType, u string) bool {
+	if mediaType, _, err := mime.ParseMediaType(contentType); err == nil &&
+		(strings.Contains(mediaType, "javascript") || strings.Contains(mediaType, "ecmascript")) {
+		return true
+	}
+	if i := strings.IndexAny(u, "?#"); i >= 0 {
+		u = u[:i]
+	}
+	u = strings.ToLower(u)
+	return strings.HasSuffix(u, ".js") || strings.HasSuffix(u, ".mjs")
+}
+
+// inlineScriptLinks finds URLs in an inline <script> or an on* event
+// handler: absolute URLs, plus the same relative paths scriptRelativePaths
+// finds in bundles, such as location.href='/account'.
+func inlineScriptLinks(js string) []string {
+	return append(scriptURLRegex.FindAllString(js, -1), scriptRelativePaths(js)...)
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// TestScriptRelativePathsCorpus runs scriptRelativePaths over the bundle
+// snippets in testdata/scripts. Each x.js lists the paths it should yield,
+// in order, in x.paths; anything missing from that list is a false
+// positive.
+func TestScriptRelativePathsCorpus(t *testing.T) {
+	files, err := filepath.Glob(filepath.Join("testdata", "scripts", "*.js"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) == 0 {
+		t.Fatal("no fixtures in testdata/scripts")
+	}
+	for _, file := range files {
+		t.Run(filepath.Base(file), func(t *testing.T) {
+			js, err := os.ReadFile(file)
+			if err != nil {
+				t.Fatal(err)
+			}
+			expected, err := os.ReadFile(strings.TrimSuffix(file, ".js") + ".paths")
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := strings.Fields(string(expected))
+			if got := scriptRelativePaths(string(js)); !reflect.DeepEqual(got, want) {
+				t.Errorf("got  %q\nwant %q", got, want)
+			}
+		})
+	}
+}
+
+func TestIsScriptPath(t *testing.T) {
+	tests := []struct {
+		s    string
+		want bool
+	}{
+		{"/api/v2/users", true},
+		{"api/orders", true},
+		{"./admin/settings", true},
+		{"../dashboard", true},
+		{"/search?q=", true},
+		{"/", false},
+		{"./", false},
+		{"//cdn.example.com/lib.js", false},
+		{"https://example.com/a", false},
+		{"application/json", false},
+		{"image/svg+xml", false},
+		{"12/31/1999", false},
+		{"dd/mm/yyyy", false},
+		{"km/h", false},
+		{"w/o", false},
+		{"v1.2/x", false},
+		{"and/or", false},
+		{"api/v2", true},
+		{"/a b", false},
+	}
+	for _, tt := range tests {
+		if got := isScriptPath(tt.s); got != tt.want {
+			t.Errorf("isScriptPath(%q) = %v, want %v", tt.s, got, tt.want)
+		}
+	}
+}
+
+func TestInlineScriptLinks(t *testing.T) {
+	tests := []struct {
+		js   string
+		want []string
+	}{
+		{"location.href='/account'", []string{"/account"}},
+		{`fetch("api/orders?id=1").then(r => r.json())`, []string{"api/orders?id=1"}},
+		{"window.open('https://example.com/help')", []string{"https://example.com/help"}},
+		{`x = a / b / c; y = "/"; z = '//cdn.example.com/x.js'`, nil},
+		{`var mime = "text/plain", when = "01/02/2024"`, nil},
+	}
+	for _, tt := range tests {
+		if got := inlineScriptLinks(tt.js); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("inlineScriptLinks(%q) = %q, want %q", tt.js, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,205 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// maxSecretSnippet is how much of a match is kept in the report.
+const maxSecretSnippet = 120
+
+// SecretRule is one pattern looked for in fetched bodies. If Pattern has a
+// capture group, the first group is the secret itself: it is what
+// MinEntropy is checked against and what gets redacted in the report.
+// Otherwise the whole match is.
+type SecretRule struct {
+	Name       string  `json:"name"`
+	Pattern    string  `json:"pattern,omitempty"`
+	MinEntropy float64 `json:"min_entropy,omitempty"`
+	Disabled   bool    `json:"disabled,omitempty"`
+
+	re *regexp.Regexp
+}
+
+// Secret is a rule match in the body of URL, with the secret redacted.
+type Secret struct {
+	URL     string `json:"url"`
+	Rule    string `json:"rule"`
+	Snippet string `json:"snippet"`
+}
+
+// defaultSecretRules only cover formats distinctive enough to be worth
+// reporting on sight. The generic assignment rule leans on entropy to
+// skip placeholders like "your_api_key_here".
+var defaultSecretRules = []SecretRule{
+	{Name: "aws-access-key-id", Pattern: `\b(?:AKIA|ASIA)[0-9A-Z]{16}\b`},
+	{Name: "google-api-key", Pattern: `\bAIza[0-9A-Za-z_\-]{35}\b`},
+	{Name: "github-token", Pattern: `\bgh[pousr]_[0-9A-Za-z]{36}\b`},
+	{Name: "slack-token", Pattern: `\bxox[abposr]-[0-9A-Za-z\-]{10,}`},
+	{Name: "slack-webhook", Pattern: `https://hooks\.slack\.com/services/([0-9A-Za-z/]{20,})`},
+	{Name: "jwt", Pattern: `\beyJ[0-9A-Za-z_\-]{10,}\.eyJ[0-9A-Za-z_\-]{10,}\.([0-9A-Za-z_\-]{10,})`},
+	{Name: "private-key", Pattern: `-----BEGIN [A-Z ]*PRIVATE KEY(?: BLOCK)?-----(?:\s*([0-9A-Za-z+/=]{8,}))?`},
+	{Name: "url-credentials", Pattern: `\b[a-zA-Z][a-zA-Z0-9+.\-]*://[^\s:/@"'<>]+:([^\s:/@"'<>]+)@[^\s/"'<>]+`},
+	{Name: "generic-secret", Pattern: `(?i)(?:api[_\-]?key|secret|token|passw(?:or)?d)["']?\s*[:=]\s*["']([0-9A-Za-z_\-+/=.]{16,})["']`, MinEntropy: 3.5},
+}
+
+// loadSecretRules returns the default rules with those in filename, a JSON
+// array of SecretRule, applied on top. A rule with the name of a default
+// one replaces its pattern and entropy threshold, or switches it off with
+// "disabled": true; any other name adds a rule. An empty filename just
+// compiles the defaults.
+func loadSecretRules(filename string) ([]*SecretRule, error) {
+	var rules []*SecretRule
+	byName := make(map[string]*SecretRule)
+	for _, r := range defaultSecretRules {
+		r := r
+		rules = append(rules, &r)
+		byName[r.Name] = &r
+	}
+
+	if filename != "" {
+		data, err := os.ReadFile(filename)
+		if err != nil {
+			return nil, err
+		}
+		var custom []SecretRule
+		if err := json.Unmarshal(data, &custom); err != nil {
+			return nil, fmt.Errorf("%s: %v", filename, err)
+		}
+		for _, r := range custom {
+			r := r
+			if existing, ok := byName[r.Name]; ok {
+				if r.Pattern != "" {
+					existing.Pattern = r.Pattern
+					existing.MinEntropy = r.MinEntropy
+				}
+				existing.Disabled = r.Disabled
+				continue
+			}
+			if r.Pattern == "" {
+				return nil, fmt.Errorf("%s: rule %q has no pattern", filename, r.Name)
+			}
+			rules = append(rules, &r)
+			byName[r.Name] = &r
+		}
+	}
+
+	var enabled []*SecretRule
+	for _, r := range rules {
+		if r.Disabled {
+			continue
+		}
+		re, err := regexp.Compile(r.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("secret rule %q: %v", r.Name, err)
+		}
+		r.re = re
+		enabled = append(enabled, r)
+	}
+	return enabled, nil
+}
+
+// findSecrets runs rules over body and returns the redacted matches.
+func findSecrets(rules []*SecretRule, body string) []Secret {
+	var found []Secret
+	for _, r := range rules {
+		for _, m := range r.re.FindAllStringSubmatchIndex(body, -1) {
+			start, end := m[0], m[1]
+			secretStart, secretEnd := start, end
+			if len(m) >= 4 && m[2] >= 0 {
+				secretStart, secretEnd = m[2], m[3]
+			} else if len(m) >= 4 {
+				// The optional secret part didn't match, so there's
+				// nothing to hide.
+				secretStart, secretEnd = end, end
+			}
+			if r.MinEntropy > 0 && entropy(body[secretStart:secretEnd]) < r.MinEntropy {
+				continue
+			}
+			snippet := body[start:secretStart] + redact(body[secretStart:secretEnd]) + body[secretEnd:end]
+			snippet = whitespaceRegex.ReplaceAllString(snippet, " ")
+			if len(snippet) > maxSecretSnippet {
+				snippet = snippet[:maxSecretSnippet] + "..."
+			}
+			found = append(found, Secret{Rule: r.Name, Snippet: snippet})
+		}
+	}
+	return found
+}
+
+// redact keeps just enough of s to tell secrets apart.
+func redact(s string) string {
+	if len(s) <= 8 {
+		return strings.Repeat("*", len(s))
+	}
+	return s[:4] + "..." + s[len(s)-2:]
+}
+
+// entropy is the Shannon entropy of s in bits per byte.
+func entropy(s string) float64 {
+	if s == "" {
+		return 0
+	}
+	var counts [256]int
+	for i := 0; i < len(s); i++ {
+		counts[s[i]]++
+	}
+	var h float64
+	for _, n := range counts {
+		if n > 0 {
+			p := float64(n) / float64(len(s))
+			h -= p * math.Log2(p)
+		}
+	}
+	return h
+}
+
+// scanSecrets records the secrets in the body fetched from pageURL, once
+// per URL, rule and snippet.
+func (c *Crawler) scanSecrets(pageURL string, body []byte) {
+	if len(c.SecretRules) == 0 || len(body) == 0 {
+		return
+	}
+	found := findSecrets(c.SecretRules, string(body))
+	if len(found) == 0 {
+		return
+	}
+	var fresh []Secret
+	c.resultMu.Lock()
+	for _, s := range found {
+		s.URL = pageURL
+		key := s.URL + " " + s.Rule + " " + s.Snippet
+		if c.secretsSeen[key] {
+			continue
+		}
+		c.secretsSeen[key] = true
+		c.result.Secrets = append(c.result.Secrets, s)
+		fresh = append(fresh, s)
+	}
+	c.resultMu.Unlock()
+
+	for _, s := range fresh {
+		infof("Possible %s in %s: %s", s.Rule, s.URL, s.Snippet)
+		c.notifyFinding(Finding{Kind: findingSecret, URL: s.URL, Detail: s.Rule + " " + s.Snippet})
+	}
+}
+
+func writeSecrets(filename string, secrets []Secret) error {
+	f, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	f.WriteString("--POSSIBLE SECRETS:---\n")
+	for _, s := range secrets {
+		if _, err := fmt.Fprintf(f, "%s [%s] %s\n", s.URL, s.Rule, s.Snippet); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// sitemapMaxURLs is the per-file limit from the sitemaps.org protocol.
+const sitemapMaxURLs = 50000
+
+const sitemapNS = "http://www.sitemaps.org/schemas/sitemap/0.9"
+
+type sitemapURLSet struct {
+	XMLName xml.Name     `xml:"urlset"`
+	XMLNS   string       `xml:"xmlns,attr"`
+	URLs    []sitemapLoc `xml:"url"`
+}
+
+type sitemapIndex struct {
+	XMLName  xml.Name     `xml:"sitemapindex"`
+	XMLNS    string       `xml:"xmlns,attr"`
+	Sitemaps []sitemapLoc `xml:"sitemap"`
+}
+
+type sitemapLoc struct {
+	Loc string `xml:"loc"`
+}
+
+// sitemapURLs picks the pages that belong in a sitemap: 200 responses with
+// an HTML content type, without query strings unless includeQueries is set.
+func sitemapURLs(pages []Page, includeQueries bool) []string {
+	seen := make(map[string]bool)
+	var urls []string
+	for _, p := range pages {
+		if p.StatusCode != http.StatusOK {
+			continue
+		}
+		mediaType, _, _ := mime.ParseMediaType(p.Header.Get("Content-Type"))
+		if mediaType != "text/html" && mediaType != "application/xhtml+xml" {
+			continue
+		}
+		u, err := url.Parse(p.URL)
+		if err != nil || (u.RawQuery != "" && !includeQueries) {
+			continue
+		}
+		u.Fragment = ""
+		loc := u.String()
+		if !seen[loc] {
+			seen[loc] = true
+			urls = append(urls, loc)
+		}
+	}
+	sort.Strings(urls)
+	return urls
+}
+
+// writeSitemap writes urls to filename. Past sitemapMaxURLs entries the
+// URLs are split over filename-1.xml, filename-2.xml, ... and filename
+// becomes a sitemap index pointing at them under baseURL.
+func writeSitemap(filename, baseURL string, urls []string) error {
+	if len(urls) <= sitemapMaxURLs {
+		return writeSitemapXML(filename, newURLSet(urls))
+	}
+
+	ext := filepath.Ext(filename)
+	stem := strings.TrimSuffix(filename, ext)
+	index := sitemapIndex{XMLNS: sitemapNS}
+	for i := 0; i*sitemapMaxURLs < len(urls); i++ {
+		end := (i + 1) * sitemapMaxURLs
+		if end > len(urls) {
+			end = len(urls)
+		}
+		part := fmt.Sprintf("%s-%d%s", stem, i+1, ext)
+		if err := writeSitemapXML(part, newURLSet(urls[i*sitemapMaxURLs:end])); err != nil {
+			return err
+		}
+		index.Sitemaps = append(index.Sitemaps, sitemapLoc{strings.TrimSuffix(baseURL, "/") + "/" + filepath.Base(part)})
+	}
+	return writeSitemapXML(filename, index)
+}
+
+func newURLSet(urls []string) sitemapURLSet {
+	set := sitemapURLSet{XMLNS: sitemapNS}
+	for _, u := range urls {
+		set.URLs = append(set.URLs, sitemapLoc{u})
+	}
+	return set
+}
+
+func writeSitemapXML(filename string, v interface{}) error {
+	f, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	f.WriteString(xml.Header)
+	enc := xml.NewEncoder(f)
+	enc.Indent("", "  ")
+	if err := enc.Encode(v); err != nil {
+		return err
+	}
+	_, err = f.WriteString("\n")
+	return err
+}
+
+// viaSitemap tags URLs listed in a sitemap.
+const viaSitemap = "sitemap"
+
+// maxSitemapSize is the sitemaps.org limit on an uncompressed sitemap.
+const maxSitemapSize = 50 << 20
+
+// sitemapDoc reads both a <urlset> and a <sitemapindex>.
+type sitemapDoc struct {
+	XMLName  xml.Name
+	URLs     []sitemapLoc `xml:"url"`
+	Sitemaps []sitemapLoc `xml:"sitemap"`
+}
+
+// isSitemap reports whether the root element of body is a <urlset> or
+// <sitemapindex>, without decoding the rest of the document.
+func isSitemap(body []byte) bool {
+	dec := newFeedDecoder(body)
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			return false
+		}
+		if se, ok := tok.(xml.StartElement); ok {
+			return se.Name.Local == "urlset" || se.Name.Local == "sitemapindex"
+		}
+	}
+}
+
+// sitemapLinks returns the <loc> of every page and child sitemap if body
+// is a sitemap or sitemap index, gzipped or not. ok is false if it is
+// neither. A sitemap that breaks off part way still yields the entries
+// before the error.
+func sitemapLinks(body []byte) (links []string, ok bool, err error) {
+	if len(body) > 2 && body[0] == 0x1f && body[1] == 0x8b {
+		zr, err := gzip.NewReader(bytes.NewReader(body))
+		if err != nil {
+			return nil, false, nil
+		}
+		body, err = io.ReadAll(io.LimitReader(zr, maxSitemapSize))
+		if err != nil && len(body) == 0 {
+			return nil, false, nil
+		}
+	}
+
+	if !isSitemap(body) {
+		return nil, false, nil
+	}
+	var doc sitemapDoc
+	err = newFeedDecoder(body).Decode(&doc)
+	for _, list := range [][]sitemapLoc{doc.Sitemaps, doc.URLs} {
+		for _, l := range list {
+			if loc := strings.TrimSpace(l.Loc); loc != "" {
+				links = append(links, loc)
+			}
+		}
+	}
+	return links, true, err
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// viaSourceMap tags URLs found through a script's source map.
+const viaSourceMap = "sourcemap"
+
+// sourceMappingURLRegex matches both the //# comment scripts end with and
+// the /*# */ form stylesheets use, as well as the old //@ spelling.
+var sourceMappingURLRegex = regexp.MustCompile(`[#@][ \t]*sourceMappingURL=([^\s'"*]+)`)
+
+type sourceMap struct {
+	SourceRoot     string   `json:"sourceRoot"`
+	Sources        []string `json:"sources"`
+	SourcesContent []string `json:"sourcesContent"`
+}
+
+// sourceMapRef returns where a script's source map is, from the SourceMap
+// header or else the last sourceMappingURL comment.
+func sourceMapRef(h http.Header, js string) string {
+	for _, name := range []string{"SourceMap", "X-SourceMap"} {
+		if v := strings.TrimSpace(h.Get(name)); v != "" {
+			return v
+		}
+	}
+	m := sourceMappingURLRegex.FindAllStringSubmatch(js, -1)
+	if len(m) == 0 {
+		return ""
+	}
+	return m[len(m)-1][1]
+}
+
+// sourceMapLinks finds the source map of the script in resp and returns
+// the map's own URL, the URLs of its original sources and any URLs or
+// relative paths written in their content. Minified bundles often lose
+// endpoint constants the original sources still have. Maps are only
+// fetched from in-scope URLs; inline data: maps are always read.
+func (c *Crawler) sourceMapLinks(ctx context.Context, resp *http.Response, js string) []string {
+	ref := sourceMapRef(resp.Header, js)
+	if ref == "" {
+		return nil
+	}
+	scriptURL := resp.Request.URL
+
+	var links []string
+	var data []byte
+	mapURL := scriptURL.String()
+	if strings.HasPrefix(ref, "data:") {
+		var err error
+		if data, err = decodeDataURI(ref); err != nil {
+			verbosef("Invalid inline source map in %s: %v", scriptURL, err)
+			return nil
+		}
+	} else {
+		mapURL = c.formatURL(mapURL, ref)
+		if !c.isValidURL(mapURL) {
+			return nil
+		}
+		links = append(links, mapURL)
+		if !c.isInScope(mapURL) {
+			return links
+		}
+		if data = c.fetchResource(WithReferer(ctx, scriptURL.String()), mapURL, "source map"); data == nil {
+			return links
+		}
+	}
+
+	var sm sourceMap
+	if err := json.Unmarshal(data, &sm); err != nil {
+		verbosef("Invalid source map %s: %v", mapURL, err)
+		return links
+	}
+
+	root := sm.SourceRoot
+	if root != "" && !strings.HasSuffix(root, "/") {
+		root += "/"
+	}
+	for _, src := range sm.Sources {
+		// Bundlers write absolute sources like webpack:///src/app.js,
+		// which sourceRoot doesn't apply to and which aren't fetchable.
+		if u, err := url.Parse(src); err != nil || !u.IsAbs() {
+			src = root + src
+		}
+		if u := c.formatURL(mapURL, src); c.isValidURL(u) {
+			links = append(links, u)
+		}
+	}
+	origin := &url.URL{Scheme: scriptURL.Scheme, Host: scriptURL.Host, Path: "/"}
+	for _, content := range sm.SourcesContent {
+		links = append(links, scriptURLRegex.FindAllString(content, -1)...)
+		for _, path := range scriptRelativePaths(content) {
+			links = append(links, c.formatURL(origin.String(), path))
+		}
+	}
+	return links
+}
+
+// decodeDataURI returns the content of a data: URI, base64 or percent
+// encoded.
+func decodeDataURI(uri string) ([]byte, error) {
+	meta, data, ok := strings.Cut(strings.TrimPrefix(uri, "data:"), ",")
+	if !ok {
+		return nil, errors.New("no comma in data: URI")
+	}
+	if strings.HasSuffix(strings.ToLower(meta), ";base64") {
+		return base64.StdEncoding.DecodeString(data)
+	}
+	s, err := url.PathUnescape(data)
+	return []byte(s), err
+}
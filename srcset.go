@@ -0,0 +1,59 @@
+package main
+
+import "strings"
+
+// defaultLazyAttributes are the attributes common lazy loading scripts
+// keep the real URL in until an element scrolls into view.
+var defaultLazyAttributes = []string{"data-src", "data-lazy-src", "data-original", "data-srcset", "data-lazy-srcset"}
+
+const srcsetSpace = " \t\n\r\f"
+
+// parseSrcset returns the URL of every candidate in a srcset value such as
+// "a.png 1x, b.png 2x" or "s.jpg 480w, l.jpg 1024w". It follows the HTML
+// parsing rules closely enough that URLs containing commas survive.
+func parseSrcset(s string) []string {
+	var urls []string
+	for {
+		s = strings.TrimLeft(s, srcsetSpace+",")
+		if s == "" {
+			return urls
+		}
+		end := strings.IndexAny(s, srcsetSpace)
+		if end < 0 {
+			end = len(s)
+		}
+		u := s[:end]
+		s = s[end:]
+
+		if trimmed := strings.TrimRight(u, ","); trimmed != u {
+			// A trailing comma ends the candidate: no descriptors.
+			u = trimmed
+		} else {
+			s = s[descriptorsEnd(s):]
+		}
+		if u != "" {
+			urls = append(urls, u)
+		}
+	}
+}
+
+// descriptorsEnd finds the comma ending a candidate's descriptors, skipping
+// commas inside parentheses.
+func descriptorsEnd(s string) int {
+	depth := 0
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '(':
+			depth++
+		case ')':
+			if depth > 0 {
+				depth--
+			}
+		case ',':
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return len(s)
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseSrcset(t *testing.T) {
+	tests := []struct {
+		srcset string
+		want   []string
+	}{
+		{"image-1x.png 1x, image-2x.png 2x", []string{"image-1x.png", "image-2x.png"}},
+		{"s.jpg 480w, m.jpg 800w,l.jpg 1024w", []string{"s.jpg", "m.jpg", "l.jpg"}},
+		{"only.png", []string{"only.png"}},
+		{"a.png, b.png", []string{"a.png", "b.png"}},
+		{"/img?w=1,2 1x, /img?w=3 2x", []string{"/img?w=1,2", "/img?w=3"}},
+		{"a.png (max-width: 1px, x) 1x, b.png 2x", []string{"a.png", "b.png"}},
+		{"\n\timage.png\n\t 1.5x ,\n other.png 3x", []string{"image.png", "other.png"}},
+		{" , ", nil},
+		{"", nil},
+	}
+	for _, tt := range tests {
+		if got := parseSrcset(tt.srcset); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseSrcset(%q) = %q, want %q", tt.srcset, got, tt.want)
+		}
+	}
+}
+
+func TestResponsiveAndLazyLinks(t *testing.T) {
+	page := `<html><body>
+<img src="/fallback.png" srcset="image-1x.png 1x, image-2x.png 2x">
+<picture><source srcset="/wide.jpg 1024w, /narrow.jpg 480w" sizes="100vw"></picture>
+<img data-src="/lazy.png" data-srcset="/lazy-1x.png 1x, /lazy-2x.png 2x">
+<video poster="/poster.jpg" src="/clip.mp4"></video>
+<link rel="preload" as="image" imagesrcset="/hero-1x.webp 1x, /hero-2x.webp 2x">
+<div data-src="{{ item.url }}"></div>
+</body></html>`
+	want := []string{
+		"https://example.com/clip.mp4",
+		"https://example.com/fallback.png",
+		"https://example.com/gallery/image-1x.png",
+		"https://example.com/gallery/image-2x.png",
+		"https://example.com/hero-1x.webp",
+		"https://example.com/hero-2x.webp",
+		"https://example.com/lazy-1x.png",
+		"https://example.com/lazy-2x.png",
+		"https://example.com/lazy.png",
+		"https://example.com/narrow.jpg",
+		"https://example.com/poster.jpg",
+		"https://example.com/wide.jpg",
+	}
+	got := pageLinks(t, NewCrawler(nil, nil), "https://example.com/gallery/", page)
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("links = %q, want %q", got, want)
+	}
+}
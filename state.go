@@ -0,0 +1,197 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// defaultStateInterval is how often -state is rewritten during a crawl.
+const defaultStateInterval = 30 * time.Second
+
+// crawlState is what -state persists: the URLs that have been fully
+// crawled, the ones still waiting in the queue and everything found so far,
+// so the output files of a resumed crawl cover both sessions.
+type crawlState struct {
+	Visited []string    `json:"visited"`
+	Queue   []QueueItem `json:"queue"`
+	Result  *Result     `json:"result,omitempty"`
+}
+
+// LoadState marks the saved URLs as visited and queues the saved pending
+// items for the next Run. A missing file is not an error, so the same
+// -state flag both starts and resumes a crawl.
+func (c *Crawler) LoadState(filename string) error {
+	data, err := os.ReadFile(filename)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var st crawlState
+	if err := json.Unmarshal(data, &st); err != nil {
+		return err
+	}
+
+	c.Mutex.Lock()
+	for _, u := range st.Visited {
+		c.Visited[u] = true
+	}
+	c.Mutex.Unlock()
+	c.resumeQueue = st.Queue
+	c.resumed = st.Result
+	return nil
+}
+
+// Resuming reports whether LoadState found results from an earlier run,
+// which the next Run carries on from.
+func (c *Crawler) Resuming() bool {
+	return c.resumed != nil
+}
+
+// restoreResult makes a loaded result the current one and rebuilds the sets
+// that keep findings unique, so nothing from the first session is reported
+// twice. It must be called with resultMu held.
+func (c *Crawler) restoreResult(res *Result) {
+	if res.Graph == nil {
+		res.Graph = NewLinkGraph()
+	}
+	c.result = res
+	for _, s := range res.Secrets {
+		c.secretsSeen[s.URL+" "+s.Rule+" "+s.Snippet] = true
+	}
+	for _, b := range res.Buckets {
+		c.bucketsSeen[b.Provider+" "+b.Bucket] = true
+	}
+	for _, e := range res.Emails {
+		c.emailsSeen[strings.ToLower(e.Address)] = true
+	}
+	for _, d := range res.InScope {
+		if u, err := url.Parse(d.URL); err == nil && u.Hostname() != "" {
+			c.hostsSeen[u.Hostname()] = true
+		}
+	}
+	// They were streamed in the first session already.
+	for _, list := range [][]Discovery{res.InScope, res.OutScope} {
+		for _, d := range list {
+			c.streamed[d.URL] = true
+		}
+	}
+}
+
+// SaveState writes the current state to a temporary file and renames it
+// over filename so an interrupted save never leaves a truncated file.
+func (c *Crawler) SaveState(filename string) error {
+	var st crawlState
+	c.Mutex.Lock()
+	for u := range c.Visited {
+		// A URL that is still being processed may not have queued its
+		// links yet, so it is saved as pending rather than visited.
+		if _, ok := c.pending[u]; !ok {
+			st.Visited = append(st.Visited, u)
+		}
+	}
+	for _, item := range c.pending {
+		st.Queue = append(st.Queue, item)
+	}
+	c.Mutex.Unlock()
+
+	// Workers append to the result and update the graph in place, so it
+	// is encoded under the lock. Request headers carry the Authorization,
+	// cookies and -H values, which have no business on disk, so the pages
+	// are saved without them.
+	c.resultMu.Lock()
+	if c.result != nil {
+		res := *c.result
+		res.Pages = make([]Page, len(c.result.Pages))
+		for i, p := range c.result.Pages {
+			p.RequestHeader = nil
+			res.Pages[i] = p
+		}
+		st.Result = &res
+	}
+	data, err := json.Marshal(st)
+	c.resultMu.Unlock()
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(filename), filepath.Base(filename)+".tmp*")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), filename)
+}
+
+// MarshalJSON saves the error as its message, which is all that survives
+// a round trip.
+func (e FetchError) MarshalJSON() ([]byte, error) {
+	return json.Marshal(savedFetchError{URL: e.URL, Err: e.Err.Error()})
+}
+
+func (e *FetchError) UnmarshalJSON(data []byte) error {
+	var saved savedFetchError
+	if err := json.Unmarshal(data, &saved); err != nil {
+		return err
+	}
+	e.URL, e.Err = saved.URL, errors.New(saved.Err)
+	return nil
+}
+
+type savedFetchError struct {
+	URL string `json:"url"`
+	Err string `json:"error"`
+}
+
+// UnmarshalJSON restores a saved graph, rebuilding the lookup maps, which
+// aren't saved.
+func (g *LinkGraph) UnmarshalJSON(data []byte) error {
+	type plainGraph LinkGraph
+	var saved plainGraph
+	if err := json.Unmarshal(data, &saved); err != nil {
+		return err
+	}
+	*g = *NewLinkGraph()
+	g.Nodes, g.Edges = saved.Nodes, saved.Edges
+	for i, n := range g.Nodes {
+		g.index[n.URL] = int32(i)
+	}
+	for _, e := range g.Edges {
+		g.edges[e] = true
+	}
+	return nil
+}
+
+// saveStatePeriodically saves state every interval until ctx is done. A
+// zero interval leaves it to the save at the end of Run.
+func (c *Crawler) saveStatePeriodically(ctx context.Context, filename string, interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := c.SaveState(filename); err != nil {
+				errorf("Could not save crawl state to %s: %v", filename, err)
+			}
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"mime"
+	"path"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// viaHostname tags host names found on their own, without a scheme, in a
+// config file. They are reported as https://host/.
+const viaHostname = "hostname"
+
+var hostnameRegex = regexp.MustCompile(`^(?:[a-z0-9](?:[a-z0-9\-]{0,61}[a-z0-9])?\.)+[a-z]{2,24}$`)
+
+// fileExtensions are "top level domains" that are really file names, like
+// app.js or config.json.
+var fileExtensions = map[string]bool{
+	"js": true, "mjs": true, "json": true, "map": true, "css": true, "html": true, "htm": true,
+	"xml": true, "yaml": true, "yml": true, "txt": true, "md": true, "php": true, "asp": true,
+	"aspx": true, "jsp": true, "png": true, "jpg": true, "jpeg": true, "gif": true, "svg": true,
+	"ico": true, "webp": true, "woff": true, "woff2": true, "ttf": true, "eot": true, "pdf": true,
+	"zip": true, "gz": true, "tar": true, "exe": true, "dll": true, "so": true, "jar": true,
+	"war": true, "class": true, "py": true, "rb": true, "go": true, "java": true, "ts": true,
+	"tsx": true, "jsx": true, "vue": true, "lock": true, "log": true, "conf": true, "ini": true,
+	"env": true, "properties": true, "csv": true, "sql": true, "sh": true, "bat": true,
+}
+
+// isHostname reports whether s is a bare host name like
+// api.internal.example.com, as opposed to a file name.
+func isHostname(s string) bool {
+	s = strings.ToLower(s)
+	if !hostnameRegex.MatchString(s) {
+		return false
+	}
+	return !fileExtensions[s[strings.LastIndexByte(s, '.')+1:]]
+}
+
+// configStrings parses a JSON, YAML or XML file and returns every string
+// value in it: JSON and YAML scalars, and XML attribute values and text.
+// ok is false if the file is none of those or doesn't parse.
+func configStrings(contentType, u string, body []byte) (values []string, ok bool) {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	ext := strings.ToLower(path.Ext(strings.SplitN(strings.SplitN(u, "?", 2)[0], "#", 2)[0]))
+	visit := func(s string) {
+		if s = strings.TrimSpace(s); s != "" {
+			values = append(values, s)
+		}
+	}
+
+	switch {
+	case isJSON(contentType, body):
+		var doc interface{}
+		if json.Unmarshal(body, &doc) != nil {
+			return nil, false
+		}
+		walkJSON(doc, 0, visit)
+	case strings.Contains(mediaType, "yaml") || ext == ".yaml" || ext == ".yml":
+		var doc interface{}
+		if yaml.Unmarshal(body, &doc) != nil {
+			return nil, false
+		}
+		walkJSON(doc, 0, visit)
+	case strings.HasSuffix(mediaType, "xml") || ext == ".xml" || bytes.HasPrefix(bytes.TrimSpace(body), []byte("<?xml")):
+		dec := newFeedDecoder(body)
+		for {
+			tok, err := dec.Token()
+			if err != nil {
+				break
+			}
+			switch tok := tok.(type) {
+			case xml.StartElement:
+				for _, a := range tok.Attr {
+					visit(a.Value)
+				}
+			case xml.CharData:
+				visit(string(tok))
+			}
+		}
+	default:
+		return nil, false
+	}
+	return values, true
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"net"
+	"net/url"
+	"strings"
+)
+
+// subdomains returns the unique host names linked to anywhere in the
+// crawl, in scope or not, including those of WebSocket and other non-HTTP
+// URLs. IP addresses are left out. With apexes set, only those hosts that
+// are one of them or under one of them are kept.
+func subdomains(res *Result, apexes []string) map[string]bool {
+	hosts := make(map[string]bool)
+	for _, list := range [][]Discovery{res.InScope, res.OutScope, res.OtherSchemes} {
+		for _, d := range list {
+			u, err := url.Parse(d.URL)
+			if err != nil || u.Hostname() == "" {
+				continue
+			}
+			host := strings.TrimSuffix(asciiHost(u.Hostname()), ".")
+			if net.ParseIP(host) != nil || !underApex(host, apexes) {
+				continue
+			}
+			hosts[host] = true
+		}
+	}
+	return hosts
+}
+
+func underApex(host string, apexes []string) bool {
+	if len(apexes) == 0 {
+		return true
+	}
+	for _, apex := range apexes {
+		if host == apex || strings.HasSuffix(host, "."+apex) {
+			return true
+		}
+	}
+	return false
+}
+
+// scopeApexes returns the domains named by the -inscope rules.
+func (c *Crawler) scopeApexes() []string {
+	var apexes []string
+	for _, r := range c.inScopeRules {
+		if apex := strings.TrimPrefix(r.host, "."); apex != "" {
+			apexes = append(apexes, apex)
+		}
+	}
+	return apexes
+}
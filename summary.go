@@ -0,0 +1,218 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// summaryFlags are echoed back in the summary as given. Any other flag
+// that is set shows as REDACTED, so a new flag carrying a password or
+// token can't leak into logs by being forgotten here.
+var summaryFlags = map[string]bool{
+	"v": true, "q": true, "log-format": true, "config": true, "url": true, "output": true,
+	"format": true, "inscope": true, "outscope": true, "burp-out": true, "include-bodies": true,
+	"har": true, "har-bodies": true, "user-agent": true, "user-agent-file": true, "cookies": true,
+	"graph": true, "graph-json": true, "graph-by-host": true, "graph-max-nodes": true,
+	"save-responses": true, "save-dir": true, "sitemap-out": true, "sitemap-include-queries": true,
+	"listen": true, "listen-replay": true, "timestamps": true, "subdomains": true,
+	"subdomains-in-scope": true, "fuzz-lists": true, "assets": true, "wordlist": true, "db": true,
+	"webhook-interval": true, "max-body-size": true, "proxy-insecure": true, "insecure": true,
+	"ca-cert": true, "client-cert": true, "client-key": true, "max-redirects": true,
+	"no-referer": true, "no-follow-redirects": true, "http1": true, "http2": true, "priority": true,
+	"resolve-hosts": true, "resolve-workers": true, "resolve": true, "dns": true,
+	"request-timeout": true, "metrics": true, "state": true, "state-interval": true,
+	"cache-dir": true, "cache-max-age": true, "no-cache": true, "code-exts": true,
+	"use-sitemaps": true, "data-attrs": true, "extra-attrs": true, "lazy-attrs": true,
+	"crawl-get-forms": true, "scan-secrets": true, "secret-rules": true, "emails-obfuscated": true,
+	"dedup-content": true, "order": true, "workers": true, "adaptive": true, "min-workers": true,
+	"max-workers": true, "head-first": true, "well-known": true, "well-known-file": true,
+	"data-uris": true, "openapi-placeholder": true, "pdf": true, "delay": true, "jitter": true,
+	"max-bytes": true, "max-bandwidth": true, "summary-json": true, "login-url": true,
+	"login-method": true, "login-token-regex": true,
+}
+
+// flagRedactors show the parts of a credential-carrying flag that are
+// safe to log, such as which cookies were sent but not their values.
+var flagRedactors = map[string]func(*flag.Flag) string{
+	"cookie": func(f *flag.Flag) string { return redactCookies(f.Value.String()) },
+	"H":      func(f *flag.Flag) string { return f.Value.(*headerFlags).Redacted() },
+	"proxy":  func(f *flag.Flag) string { return redactURL(f.Value.String()) },
+}
+
+// summaryFlagValue is how a flag is shown in the summary.
+func summaryFlagValue(f *flag.Flag) string {
+	value := f.Value.String()
+	if value == "" || summaryFlags[f.Name] {
+		return value
+	}
+	if redact, ok := flagRedactors[f.Name]; ok {
+		return redact(f)
+	}
+	return "REDACTED"
+}
+
+// redactURL hides the password in a URL's user info.
+func redactURL(value string) string {
+	u, err := url.Parse(value)
+	if err != nil {
+		return "REDACTED"
+	}
+	return u.Redacted()
+}
+
+// redactCookies keeps the names in a "name=value; other=value" list.
+func redactCookies(value string) string {
+	var parts []string
+	for _, part := range strings.Split(value, ";") {
+		name, _, _ := strings.Cut(strings.TrimSpace(part), "=")
+		if name != "" {
+			parts = append(parts, name+"=REDACTED")
+		}
+	}
+	return strings.Join(parts, "; ")
+}
+
+// CrawlStats are running totals updated by the workers as they go.
+type CrawlStats struct {
+	Pages  atomic.Int64
+	Errors atomic.Int64
+	Bytes  atomic.Int64
+
+	// ByteLimitReached is set once Crawler.MaxBytes stops the crawl.
+	ByteLimitReached atomic.Bool
+
+	RequestDurations durationHistogram
+	Throughput       throughputMeter
+}
+
+// Summary describes a finished crawl: when it ran, with which version and
+// flags, and how much it covered.
+type Summary struct {
+	Version    string            `json:"version"`
+	StartedAt  time.Time         `json:"started_at"`
+	FinishedAt time.Time         `json:"finished_at"`
+	Elapsed    float64           `json:"elapsed_seconds"`
+	Pages      int64             `json:"pages"`
+	InScope    int               `json:"in_scope"`
+	OutScope   int               `json:"out_of_scope"`
+	Hosts      int               `json:"hosts"`
+	Errors     int64             `json:"errors"`
+	Bytes      int64             `json:"bytes"`
+	AvgRate    int64             `json:"avg_bytes_per_sec"`
+	PeakRate   int64             `json:"peak_bytes_per_sec"`
+	Servers    map[string]int    `json:"servers"`
+	Flags      map[string]string `json:"flags"`
+
+	ByteLimitReached bool `json:"byte_limit_reached,omitempty"`
+}
+
+// newSummary combines the worker counters with the unique URL and host
+// counts, which need the whole result to deduplicate.
+func newSummary(res *Result, stats *CrawlStats) Summary {
+	s := Summary{
+		Version:    crawlerVersion,
+		StartedAt:  res.StartedAt,
+		FinishedAt: res.FinishedAt,
+		Elapsed:    res.FinishedAt.Sub(res.StartedAt).Seconds(),
+		Pages:      stats.Pages.Load(),
+		Errors:     stats.Errors.Load(),
+		Bytes:      stats.Bytes.Load(),
+		PeakRate:   stats.Throughput.Peak(),
+		Flags:      make(map[string]string),
+
+		ByteLimitReached: stats.ByteLimitReached.Load(),
+	}
+
+	hosts := make(map[string]bool)
+	for _, list := range [][]Discovery{res.InScope, res.OutScope} {
+		for _, d := range list {
+			if u, err := url.Parse(d.URL); err == nil && u.Host != "" {
+				hosts[u.Host] = true
+			}
+		}
+	}
+	if s.Elapsed > 0 {
+		s.AvgRate = int64(float64(s.Bytes) / s.Elapsed)
+	}
+	s.Hosts = len(hosts)
+	s.Servers = serverCounts(res.Pages)
+	s.InScope = uniqueURLs(res.InScope)
+	s.OutScope = uniqueURLs(res.OutScope)
+
+	flag.VisitAll(func(f *flag.Flag) {
+		s.Flags[f.Name] = summaryFlagValue(f)
+	})
+	return s
+}
+
+func (s Summary) Print() {
+	infof("url-scan %s", s.Version)
+	infof("Crawl started %s, finished %s (%s)",
+		s.StartedAt.Format(time.RFC3339), s.FinishedAt.Format(time.RFC3339),
+		s.FinishedAt.Sub(s.StartedAt).Round(time.Second))
+	flag.Visit(func(f *flag.Flag) {
+		infof("  -%s=%s", f.Name, s.Flags[f.Name])
+	})
+	infof("Pages crawled: %d, errors: %d, downloaded: %d bytes (%d bytes/s average, %d bytes/s peak)",
+		s.Pages, s.Errors, s.Bytes, s.AvgRate, s.PeakRate)
+	if s.ByteLimitReached {
+		infof("The crawl stopped early because -max-bytes was reached")
+	}
+	infof("Unique URLs: %d in scope, %d out of scope, across %d hosts", s.InScope, s.OutScope, s.Hosts)
+
+	servers := make([]string, 0, len(s.Servers))
+	for server := range s.Servers {
+		servers = append(servers, server)
+	}
+	sort.Slice(servers, func(i, j int) bool {
+		if s.Servers[servers[i]] != s.Servers[servers[j]] {
+			return s.Servers[servers[i]] > s.Servers[servers[j]]
+		}
+		return servers[i] < servers[j]
+	})
+	for _, server := range servers {
+		infof("  Server %q: %d hosts", server, s.Servers[server])
+	}
+}
+
+// serverCounts counts in-scope hosts by the Server header of the first
+// response seen from each.
+func serverCounts(pages []Page) map[string]int {
+	seen := make(map[string]bool)
+	counts := make(map[string]int)
+	for _, p := range pages {
+		u, err := url.Parse(p.URL)
+		if err != nil || seen[u.Host] {
+			continue
+		}
+		seen[u.Host] = true
+		server := p.Header.Get("Server")
+		if server == "" {
+			server = "(none)"
+		}
+		counts[server]++
+	}
+	return counts
+}
+
+func (s Summary) WriteFile(filename string) error {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filename, append(data, '\n'), 0644)
+}
+
+func uniqueURLs(list []Discovery) int {
+	seen := make(map[string]bool)
+	for _, d := range list {
+		seen[d.URL] = true
+	}
+	return len(seen)
+}
@@ -0,0 +1 @@
+var e="//cdn.example.com/lib.js",t="https://api.example.com/v1/",n='/assets/i18n/en.json',r="assets/config.json",i="a/b",s="/graphql",m="image/svg+xml";this.http.get(`${environment.api}/items`);this.router.navigate(["/orders/history"]);var w="<div class=\"x\">a/b</div>";
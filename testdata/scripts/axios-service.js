@@ -0,0 +1,15 @@
+import axios from 'axios';
+import { API_ROOT } from './config';
+
+const client = axios.create({ baseURL: '/api', timeout: 5000 });
+
+export const getProfile = () => client.get('/me/profile');
+export const updateSettings = (data) => axios.put("/account/settings", data);
+export function search(q) {
+  return fetch('/search?q=' + encodeURIComponent(q));
+}
+
+const ACCEPT = 'application/vnd.api+json';
+// see https://docs.example.com/api for details
+const dateFormat = 'dd/mm/yyyy';
+const speed = 'km/h';
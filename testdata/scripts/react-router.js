@@ -0,0 +1,5 @@
+const routes=[{path:"/",element:Home},{path:"/admin/settings",element:Settings},{path:"/admin/users/:id",element:User},{path:"*",element:NotFound}];
+const legacy={"/old-login":"/login"};
+function depth(s){return s.split("/").length}
+var ratio=width/height/2;
+history.push('../dashboard');
@@ -0,0 +1 @@
+(self.webpackChunkapp=self.webpackChunkapp||[]).push([[179],{4812:(e,t,n)=>{"use strict";n.d(t,{Z:()=>o});var r=n(9669),a=n.n(r);const s="/api/v2",o={list:()=>a().get(s+"/users"),get:e=>a().get(`/api/v2/users/${e}`),create:e=>a().post("/api/v2/users",e),orders:()=>a().get("api/orders?limit=50&sort=-created"),avatar:"./static/media/avatar.3f2a1b.png"};n.p="/static/js/";var i={"content-type":"application/json",accept:"text/html; charset=utf-8"},l="MM/DD/YYYY",c="12/31/1999",u=/^\/+|\/+$/g,d=e=>e/2,p="km/h",h="w/o"}}]);
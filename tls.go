@@ -0,0 +1,83 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// tlsConfig returns the transport's TLS config, creating it on first use.
+func (f *HTTPFetcher) tlsConfig() *tls.Config {
+	if f.Transport.TLSClientConfig == nil {
+		f.Transport.TLSClientConfig = &tls.Config{}
+	}
+	return f.Transport.TLSClientConfig
+}
+
+// SetInsecure turns off certificate verification.
+func (f *HTTPFetcher) SetInsecure() {
+	f.tlsConfig().InsecureSkipVerify = true
+}
+
+// AddRootCAs trusts the PEM certificates in filename on top of the system
+// roots.
+func (f *HTTPFetcher) AddRootCAs(filename string) error {
+	pem, err := os.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+	pool, err := x509.SystemCertPool()
+	if err != nil {
+		pool = x509.NewCertPool()
+	}
+	if !pool.AppendCertsFromPEM(pem) {
+		return fmt.Errorf("no certificates found in %s", filename)
+	}
+	f.tlsConfig().RootCAs = pool
+	return nil
+}
+
+// SetClientCert presents the given certificate and key for mutual TLS.
+func (f *HTTPFetcher) SetClientCert(certFile, keyFile string) error {
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return err
+	}
+	f.tlsConfig().Certificates = []tls.Certificate{cert}
+	return nil
+}
+
+// isTLSError reports whether err came from the TLS layer: a handshake that
+// failed or timed out, a bad certificate, or a server that doesn't speak
+// TLS at all.
+func isTLSError(err error) bool {
+	var verr *tls.CertificateVerificationError
+	var rerr tls.RecordHeaderError
+	var aerr tls.AlertError
+	var uerr x509.UnknownAuthorityError
+	var herr x509.HostnameError
+	var cerr x509.CertificateInvalidError
+	if errors.As(err, &verr) || errors.As(err, &rerr) || errors.As(err, &aerr) ||
+		errors.As(err, &uerr) || errors.As(err, &herr) || errors.As(err, &cerr) {
+		return true
+	}
+	// Handshake timeouts and most alerts from the peer only survive as text.
+	msg := err.Error()
+	return strings.Contains(msg, "tls: ") || strings.Contains(msg, "TLS handshake")
+}
+
+// certHint points certificate verification failures at -insecure and
+// -ca-cert, which are what most people hitting them need.
+func certHint(err error) error {
+	var verr *tls.CertificateVerificationError
+	var uerr x509.UnknownAuthorityError
+	var herr x509.HostnameError
+	var cerr x509.CertificateInvalidError
+	if errors.As(err, &verr) || errors.As(err, &uerr) || errors.As(err, &herr) || errors.As(err, &cerr) {
+		return fmt.Errorf("%w (use -insecure to skip certificate verification or -ca-cert to trust the issuer)", err)
+	}
+	return err
+}
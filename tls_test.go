@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"encoding/pem"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFetchTLSOptions(t *testing.T) {
+	srv, _ := newCountingServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}), true)
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	ca := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw})
+	if err := os.WriteFile(caFile, ca, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		setup func(f *HTTPFetcher) error
+		ok    bool
+	}{
+		{"default", func(*HTTPFetcher) error { return nil }, false},
+		{"insecure", func(f *HTTPFetcher) error { f.SetInsecure(); return nil }, true},
+		{"ca-cert", func(f *HTTPFetcher) error { return f.AddRootCAs(caFile) }, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := NewHTTPFetcher()
+			if err := tt.setup(f); err != nil {
+				t.Fatal(err)
+			}
+			resp, err := f.Fetch(context.Background(), srv.URL+"/")
+			if !tt.ok {
+				if err == nil {
+					resp.Body.Close()
+					t.Fatal("fetch with an untrusted certificate succeeded")
+				}
+				if !strings.Contains(err.Error(), "-insecure") || !strings.Contains(err.Error(), "-ca-cert") {
+					t.Errorf("error %q does not mention -insecure and -ca-cert", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("fetch: %v", err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode != http.StatusOK {
+				t.Errorf("status %d, want 200", resp.StatusCode)
+			}
+		})
+	}
+}
+
+func TestAddRootCAsRejectsFileWithoutCerts(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "empty.pem")
+	if err := os.WriteFile(file, []byte("not a certificate\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := NewHTTPFetcher().AddRootCAs(file); err == nil {
+		t.Error("AddRootCAs accepted a file with no certificates")
+	}
+}
@@ -1,14 +1,18 @@
 package main
 
 import (
+	"bytes"
 	"context"
+	"errors"
 	"flag"
 	"fmt"
 	"io"
-	"log"
+	"mime"
 	"net/http"
 	"net/url"
 	"os"
+	"os/signal"
+	"path"
 	"regexp"
 	"strings"
 	"sync"
@@ -17,57 +21,564 @@ import (
 	"github.com/chromedp/chromedp"
 	"github.com/chromedp/cdproto/network"
 	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+	"golang.org/x/net/html/charset"
 )
 
+const crawlerVersion = "1.0.0"
+
 type Crawler struct {
-	Queue    chan string
 	Visited  map[string]bool
 	Mutex    sync.Mutex
 	WG       sync.WaitGroup
 	OutputCh chan string
 	InScope  []string
 	OutScope []string
+
+	KeepBodies  bool
+	MaxBodySize int64
+	Fetcher     Fetcher
+	Saver       *ResponseSaver
+
+	// DepthFirst crawls the most recently found URL next instead of the
+	// oldest. The order is exact with one worker. With several, each takes
+	// the next URL as it becomes free, so pages are started in that order
+	// but finish in whatever order their servers answer, and the links
+	// they add are interleaved accordingly.
+	DepthFirst bool
+
+	// CrawlGetForms queues the URL each GET form submits to with its
+	// default values, as if it had been submitted unchanged.
+	CrawlGetForms bool
+
+	// DedupContent skips link extraction for pages whose content, give or
+	// take whitespace, was already seen at another URL, e.g. the same page
+	// with a different session ID, and for pages whose rel=canonical URL
+	// was already crawled. They are listed in Result.Duplicates.
+	DedupContent bool
+
+	// Priorities makes URLs matching them crawled before the rest, highest
+	// priority first. The first matching rule decides a URL's priority;
+	// DepthFirst only orders URLs of equal priority.
+	Priorities []PriorityRule
+
+	// Workers is how many URLs are crawled at once. With Adaptive set the
+	// number instead moves between MinWorkers and MaxWorkers, rising while
+	// the server answers quickly and backing off when latency climbs or
+	// requests start failing.
+	Workers    int
+	Adaptive   bool
+	MinWorkers int
+	MaxWorkers int
+
+	// LazyAttributes are extra attributes, on any element, whose value is
+	// a URL (or a srcset, if the name ends in "srcset").
+	LazyAttributes []string
+
+	// CodeExtensions are the file extensions, lower case with the dot,
+	// of URLs that are fetched and searched for more URLs even when out of
+	// scope.
+	CodeExtensions map[string]bool
+
+	// UseSitemaps makes the <loc> URLs of sitemaps and sitemap indexes,
+	// gzipped or not, be crawled. NewCrawler turns it on.
+	UseSitemaps bool
+
+	// DataAttrs makes every data-* attribute whose value looks like a URL
+	// or path, or is JSON containing some, a source of links.
+	DataAttrs bool
+
+	// WellKnown are paths, such as /robots.txt, requested once on every
+	// in-scope origin the crawl reaches.
+	WellKnown []string
+
+	// DataURIs makes data: URIs holding HTML, SVG, CSS, JSON or JavaScript
+	// searched for links. Otherwise they are ignored.
+	DataURIs bool
+
+	// APIPlaceholder, if set, replaces path parameters such as {id} in the
+	// endpoints read from OpenAPI and Swagger documents. Otherwise they are
+	// reported as written.
+	APIPlaceholder string
+
+	// ParsePDF makes PDF documents yield the targets of their links and
+	// bookmarks and the URLs in their text instead of being searched as
+	// raw bytes. Documents too damaged to parse still are.
+	ParsePDF bool
+
+	// Chrome makes Run finish by loading each seed in headless Chrome and
+	// recording every request the page makes. The browser goes to the
+	// network on its own, not through Fetcher, so it is off unless set.
+	// ChromeOptions are added to chromedp's defaults when it starts, e.g.
+	// to send it through the same proxy.
+	Chrome        bool
+	ChromeOptions []chromedp.ExecAllocatorOption
+
+	// HeadFirst sends a HEAD request before every GET and skips the
+	// download when the content type is not one links are extracted from.
+	// It needs a Fetcher that implements HeadFetcher.
+	HeadFirst bool
+
+	// MaxBandwidth caps the combined download rate in bytes per second.
+	// Zero means no limit. It must be set before Run.
+	MaxBandwidth int64
+
+	// Delay is the pause between the start of one request and the next,
+	// across all workers. With Jitter each pause is instead picked at
+	// random from [Delay-Jitter, Delay+Jitter].
+	Delay  time.Duration
+	Jitter time.Duration
+
+	// SecretRules, if set, are run over every body the crawl downloads
+	// anyway, and what they match is kept in Result.Secrets. No extra
+	// requests are made for them.
+	SecretRules []*SecretRule
+
+	// ObfuscatedEmails also collects addresses written out to dodge
+	// scrapers, like "user [at] example [dot] com".
+	ObfuscatedEmails bool
+
+	// MaxBytes stops the crawl once this many body bytes have been
+	// downloaded: no new URLs are fetched and Run returns what was found
+	// so far. Downloads already under way still finish, so the total can
+	// end up somewhat higher. Zero means no limit.
+	MaxBytes int64
+
+	// OnURL, if set, is called once for every URL processURL fetches, with
+	// status 0 when the request failed. It may be called concurrently from
+	// several workers, so it must be safe for concurrent use.
+	OnURL func(url string, status int, inScope bool)
+
+	// OnFinding, if set, is called for every new secret, every 5xx
+	// response and the first in-scope URL on each host. It may be called
+	// concurrently from several workers, never with the crawler's locks
+	// held, but the worker waits for it, so it should return quickly.
+	OnFinding func(Finding)
+
+	// Stream, if set, receives a URLResult for every fetched URL and for
+	// every unique URL found that won't be fetched. The caller owns the
+	// channel and must keep draining it until Run returns.
+	Stream chan<- URLResult
+
+	Stats CrawlStats
+
+	// StateFile, if set, is rewritten every StateInterval and when Run
+	// finishes so an interrupted crawl can be resumed with LoadState.
+	StateFile     string
+	StateInterval time.Duration
+
+	bandwidth   *byteLimiter
+	pacer       *requestPacer
+	concurrency *adaptiveLimiter
+	frontier    *workQueue
+
+	contentHashes map[string]string
+	canonicals    map[string]string
+	secretsSeen   map[string]bool
+	emailsSeen    map[string]bool
+	hostsSeen     map[string]bool
+	bucketsSeen   map[string]bool
+	originsProbed map[string]bool
+
+	inScopeRules  []scopeRule
+	outScopeRules []scopeRule
+
+	pending     map[string]QueueItem
+	resumeQueue []QueueItem
+	resumed     *Result
+
+	resultMu sync.Mutex
+	result   *Result
+	streamed map[string]bool
+}
+
+// QueueItem is a URL waiting to be crawled together with the page it was
+// found on and its distance from the seed.
+type QueueItem struct {
+	URL          string    `json:"url"`
+	Source       string    `json:"source,omitempty"`
+	Depth        int       `json:"depth"`
+	DiscoveredAt time.Time `json:"discovered_at"`
+
+	// Probe marks a URL queued on the off chance it exists, like the
+	// Crawler.WellKnown paths. It only counts as found once it is there.
+	Probe bool `json:"probe,omitempty"`
+}
+
+type URLResult struct {
+	URL          string     `json:"url"`
+	Scope        string     `json:"scope"`
+	Source       string     `json:"source,omitempty"`
+	Status       int        `json:"status,omitempty"`
+	Proto        string     `json:"proto,omitempty"`
+	Via          string     `json:"via,omitempty"`
+	Depth        int        `json:"depth"`
+	DiscoveredAt time.Time  `json:"discovered_at"`
+	FetchedAt    *time.Time `json:"fetched_at,omitempty"`
+
+	// Fingerprinting details taken from the response headers.
+	Server        string  `json:"server,omitempty"`
+	PoweredBy     string  `json:"x_powered_by,omitempty"`
+	ContentType   string  `json:"content_type,omitempty"`
+	ContentLength int64   `json:"content_length,omitempty"`
+	DurationMS    float64 `json:"duration_ms,omitempty"`
+}
+
+type Result struct {
+	InScope    []Discovery
+	OutScope   []Discovery
+	Errors     []FetchError
+	Pages      []Page
+	Graph      *LinkGraph
+	Forms      []Form
+	Redirects  []Redirect
+	StartedAt  time.Time
+	FinishedAt time.Time
+
+	// OtherSchemes are ws:, mailto: and similar links, kept for reporting
+	// but never crawled.
+	OtherSchemes []Discovery
+
+	// Duplicates are pages skipped by Crawler.DedupContent.
+	Duplicates []Duplicate
+
+	// Secrets are Crawler.SecretRules matches, redacted.
+	Secrets []Secret
+
+	// Emails are the unique addresses in mailto: links and in the text of
+	// everything downloaded, each with the first page it was found on.
+	Emails []Email
+
+	// Buckets are the cloud storage buckets referenced anywhere, in or
+	// out of scope. They are reported, never probed.
+	Buckets []CloudBucket
+}
+
+// Discovery is one sighting of a URL on the page (or script) Source.
+type Discovery struct {
+	URL          string
+	Source       string
+	DiscoveredAt time.Time
+
+	// Via says how a URL was found when it wasn't an ordinary link, e.g.
+	// "js-relative" for a path guessed from a script.
+	Via string
+}
+
+// Page is a single in-scope response. Body is only kept when
+// Crawler.KeepBodies is set, and never saved with -state; Size is always
+// the full body length. RequestHeader isn't saved with -state either.
+type Page struct {
+	URL           string
+	Method        string
+	StatusCode    int
+	Proto         string
+	RequestHeader http.Header
+	Header        http.Header
+	Size          int
+	Body          []byte `json:"-"`
+	FetchedAt     time.Time
+}
+
+type FetchError struct {
+	URL string
+	Err error
+}
+
+func (e FetchError) Error() string {
+	return fmt.Sprintf("%s: %v", e.URL, e.Err)
 }
 
 func NewCrawler(inscope, outscope []string) *Crawler {
 	return &Crawler{
-		Queue:    make(chan string, 100),
 		Visited:  make(map[string]bool),
+		pending:  make(map[string]QueueItem),
 		OutputCh: make(chan string),
 		InScope:  inscope,
 		OutScope: outscope,
+		Fetcher:  NewHTTPFetcher(),
+		frontier: newWorkQueue(),
+
+		contentHashes: make(map[string]string),
+		canonicals:    make(map[string]string),
+		secretsSeen:   make(map[string]bool),
+		emailsSeen:    make(map[string]bool),
+		hostsSeen:     make(map[string]bool),
+		bucketsSeen:   make(map[string]bool),
+		originsProbed: make(map[string]bool),
+
+		LazyAttributes: defaultLazyAttributes,
+		UseSitemaps:    true,
+		CodeExtensions: parseCodeExtensions(""),
+
+		inScopeRules:  parseScope(inscope),
+		outScopeRules: parseScope(outscope),
 	}
 }
 
-func (c *Crawler) Crawl(startURL string, outputFile string) {
-	inScopeFile := outputFile + "_in_scope.txt"
-	outScopeFile := outputFile + "_out_scope.txt"
+// Run crawls from the given seeds and returns everything it discovered. The
+// returned Result is populated even when ctx is cancelled part way through.
+func (c *Crawler) Run(ctx context.Context, seeds []string) (*Result, error) {
+	if len(seeds) == 0 {
+		return nil, errors.New("no seed URLs provided")
+	}
 
-	inScopeCh := make(chan string)
-	outScopeCh := make(chan string)
+	c.resultMu.Lock()
+	started := time.Now()
+	c.result = &Result{Graph: NewLinkGraph(), StartedAt: started}
+	c.streamed = make(map[string]bool)
+	if c.resumed != nil {
+		c.restoreResult(c.resumed)
+		c.resumed = nil
+	}
+	c.resultMu.Unlock()
 
-	go c.writeToFiles(inScopeFile, outScopeFile, inScopeCh, outScopeCh)
+	if c.MaxBandwidth > 0 {
+		c.bandwidth = newByteLimiter(c.MaxBandwidth)
+	}
+	if c.Delay > 0 || c.Jitter > 0 {
+		c.pacer = &requestPacer{delay: c.Delay, jitter: c.Jitter}
+	}
 
-	c.Queue <- startURL
-	c.WG.Add(1)
-	go c.worker(inScopeCh, outScopeCh)
+	if c.StateFile != "" {
+		stateCtx, stopSaving := context.WithCancel(ctx)
+		go c.saveStatePeriodically(stateCtx, c.StateFile, c.StateInterval)
+		defer func() {
+			stopSaving()
+			if err := c.SaveState(c.StateFile); err != nil {
+				errorf("Could not save crawl state to %s: %v", c.StateFile, err)
+			}
+		}()
+	}
+
+	var priority func(string) int
+	if len(c.Priorities) > 0 {
+		priority = func(u string) int { return urlPriority(c.Priorities, u) }
+	}
+	c.frontier.setOrder(c.DepthFirst, priority)
+	workers := max(c.Workers, 1)
+	if c.Adaptive {
+		c.concurrency = newAdaptiveLimiter(c.MinWorkers, c.MaxWorkers)
+		workers = c.concurrency.max
+	}
+	for i := 0; i < workers; i++ {
+		go c.worker(ctx)
+	}
+	for _, seed := range seeds {
+		c.enqueue(QueueItem{URL: normalizeURL(seed), DiscoveredAt: started})
+	}
+	for _, item := range c.resumeQueue {
+		c.enqueue(item)
+	}
+	c.resumeQueue = nil
 	c.WG.Wait()
+	c.frontier.close()
+	if c.concurrency != nil {
+		c.concurrency.close()
+	}
+
+	for _, seed := range seeds {
+		if !c.Chrome || ctx.Err() != nil || c.overBudget() {
+			break
+		}
+		c.CrawlWithChrome(ctx, seed)
+	}
+
+	c.resultMu.Lock()
+	c.result.FinishedAt = time.Now()
+	c.resultMu.Unlock()
+	return c.result, ctx.Err()
+}
+
+func (c *Crawler) recordInScope(d Discovery) {
+	host := ""
+	if parsed, err := url.Parse(d.URL); err == nil {
+		host = parsed.Hostname()
+	}
+	c.resultMu.Lock()
+	c.result.InScope = append(c.result.InScope, d)
+	newHost := host != "" && !c.hostsSeen[host]
+	if newHost {
+		c.hostsSeen[host] = true
+	}
+	c.resultMu.Unlock()
+	if newHost {
+		c.notifyFinding(Finding{Kind: findingNewHost, URL: d.URL, Host: host})
+	}
+}
+
+func (c *Crawler) recordOutScope(d Discovery) {
+	c.resultMu.Lock()
+	c.result.OutScope = append(c.result.OutScope, d)
+	c.resultMu.Unlock()
+}
+
+func (c *Crawler) recordLink(source, target string) {
+	sourceInScope, targetInScope := c.isInScope(source), c.isInScope(target)
+	c.resultMu.Lock()
+	c.result.Graph.AddLink(source, target, sourceInScope, targetInScope)
+	c.resultMu.Unlock()
+}
+
+func (c *Crawler) recordPage(resp *http.Response, body []byte) {
+	p := Page{
+		URL:           resp.Request.URL.String(),
+		Method:        resp.Request.Method,
+		StatusCode:    resp.StatusCode,
+		Proto:         resp.Proto,
+		RequestHeader: resp.Request.Header.Clone(),
+		Header:        resp.Header.Clone(),
+		Size:          len(body),
+		FetchedAt:     time.Now(),
+	}
+	if c.KeepBodies {
+		p.Body = body
+	}
+
+	c.resultMu.Lock()
+	c.result.Pages = append(c.result.Pages, p)
+	c.resultMu.Unlock()
+}
+
+func (c *Crawler) recordError(u string, err error) {
+	c.Stats.Errors.Add(1)
+	c.resultMu.Lock()
+	c.result.Errors = append(c.result.Errors, FetchError{URL: u, Err: err})
+	c.resultMu.Unlock()
+}
+
+// notifyURL reports a fetched URL. resp is nil when the request failed.
+// size is the length of the body as read, after any Content-Encoding was
+// undone, or -1 if it isn't known.
+func (c *Crawler) notifyURL(item QueueItem, resp *http.Response, elapsed time.Duration, size int64) {
+	status := 0
+	if resp != nil {
+		status = resp.StatusCode
+	}
+	inScope := c.isInScope(item.URL)
+	c.resultMu.Lock()
+	c.result.Graph.SetStatus(item.URL, inScope, status)
+	c.resultMu.Unlock()
+
+	if c.OnURL != nil {
+		c.OnURL(item.URL, status, inScope)
+	}
+	if item.Probe && status != 0 && status != http.StatusNotFound {
+		c.recordInScope(Discovery{URL: item.URL, Source: item.Source, DiscoveredAt: item.DiscoveredAt, Via: viaWellKnown})
+	}
+	if status >= 500 {
+		c.notifyFinding(Finding{Kind: findingServerError, URL: item.URL, Status: status})
+	}
+	if c.Stream != nil {
+		fetchedAt := time.Now()
+		r := URLResult{
+			URL:          item.URL,
+			Scope:        scopeName(inScope),
+			Source:       item.Source,
+			Status:       status,
+			Depth:        item.Depth,
+			DiscoveredAt: item.DiscoveredAt,
+			FetchedAt:    &fetchedAt,
+			DurationMS:   millis(elapsed),
+		}
+		if resp != nil {
+			r.Proto = resp.Proto
+			r.Server = resp.Header.Get("Server")
+			r.PoweredBy = resp.Header.Get("X-Powered-By")
+			r.ContentType = resp.Header.Get("Content-Type")
+			if size > 0 {
+				r.ContentLength = size
+			}
+		}
+		c.Stream <- r
+	}
+}
 
-	c.CrawlWithChrome(startURL, inScopeCh, outScopeCh)
+// emitDiscovered streams a URL that processURL will never fetch, once.
+func (c *Crawler) emitDiscovered(d Discovery, depth int, inScope bool) {
+	u := d.URL
+	if c.Stream == nil {
+		return
+	}
+	if inScope {
+		c.Mutex.Lock()
+		fetched := c.Visited[u]
+		c.Mutex.Unlock()
+		if fetched {
+			return
+		}
+	}
+	c.resultMu.Lock()
+	seen := c.streamed[u]
+	c.streamed[u] = true
+	c.resultMu.Unlock()
+	if seen {
+		return
+	}
+	c.Stream <- URLResult{
+		URL:          u,
+		Scope:        scopeName(inScope),
+		Source:       d.Source,
+		Via:          d.Via,
+		Depth:        depth,
+		DiscoveredAt: d.DiscoveredAt,
+	}
+}
 
-	close(inScopeCh)
-	close(outScopeCh)
-	log.Println("SCAN FINISHED")
+func scopeName(inScope bool) string {
+	if inScope {
+		return "in"
+	}
+	return "out"
+}
+
+func (c *Crawler) enqueue(item QueueItem) {
+	c.Mutex.Lock()
+	if !c.Visited[item.URL] {
+		c.pending[item.URL] = item
+	}
+	c.Mutex.Unlock()
+	c.WG.Add(1)
+	c.frontier.push(item)
+	c.probeWellKnown(item.URL, item.Depth)
 }
 
-func (c *Crawler) worker(inScopeCh, outScopeCh chan<- string) {
-	for url := range c.Queue {
-		c.processURL(url, inScopeCh, outScopeCh)
+func (c *Crawler) worker(ctx context.Context) {
+	for {
+		// In adaptive mode every worker exists from the start, but only
+		// as many as the limiter allows take work off the queue.
+		if c.concurrency != nil && !c.concurrency.acquire() {
+			return
+		}
+		item, ok := c.frontier.next()
+		if !ok {
+			if c.concurrency != nil {
+				c.concurrency.release()
+			}
+			return
+		}
+		c.processURL(ctx, item)
+		if c.concurrency != nil {
+			c.concurrency.release()
+		}
+		// Once processed, its links are queued and it no longer needs to
+		// be saved as pending. A cancelled crawl leaves it pending, as
+		// does one that ran out of -max-bytes.
+		if ctx.Err() == nil && !c.Stats.ByteLimitReached.Load() {
+			c.Mutex.Lock()
+			delete(c.pending, item.URL)
+			c.Mutex.Unlock()
+		}
 		c.WG.Done()
 	}
 }
 
-func (c *Crawler) processURL(pageURL string, inScopeCh, outScopeCh chan<- string) {
+func (c *Crawler) processURL(ctx context.Context, item QueueItem) {
+	pageURL := item.URL
+	if ctx.Err() != nil || c.overBudget() {
+		return
+	}
+
 	c.Mutex.Lock()
 	if c.Visited[pageURL] {
 		c.Mutex.Unlock()
@@ -76,44 +587,274 @@ func (c *Crawler) processURL(pageURL string, inScopeCh, outScopeCh chan<- string
 	c.Visited[pageURL] = true
 	c.Mutex.Unlock()
 
-	fmt.Println("Crawling:", pageURL)
-	resp, err := c.fetchURL(pageURL)
-	if err != nil || resp.StatusCode != http.StatusOK {
-		log.Printf("Error fetching URL %s: %v", pageURL, err)
+	infof("Crawling: %s", pageURL)
+	fetchCtx := ctx
+	if item.Source != "" {
+		fetchCtx = WithReferer(ctx, item.Source)
+	}
+	if resp, elapsed, ok := c.headOnly(fetchCtx, pageURL); ok {
+		c.Stats.Pages.Add(1)
+		c.notifyURL(item, resp, elapsed, resp.ContentLength)
+		c.recordPage(resp, nil)
+		return
+	}
+	resp, elapsed, err := c.fetchURL(fetchCtx, pageURL)
+	if err != nil {
+		errorf("Error fetching URL %s: %v", pageURL, err)
+		c.recordError(pageURL, err)
+		c.notifyURL(item, nil, elapsed, -1)
 		return
 	}
 	defer resp.Body.Close()
+	c.Stats.Pages.Add(1)
+
+	if r := redirectChain(resp); r != nil {
+		c.resultMu.Lock()
+		c.result.Redirects = append(c.result.Redirects, *r)
+		c.resultMu.Unlock()
+		verbosef("Redirect %s %s", pageURL, r)
+
+		// The final URL is what was actually crawled, so it is the one
+		// that has to be in scope and not already visited.
+		if !c.isInScope(r.Final) {
+			c.notifyURL(item, resp, elapsed, -1)
+			c.recordLink(pageURL, r.Final)
+			d := Discovery{URL: r.Final, Source: pageURL, DiscoveredAt: time.Now()}
+			c.recordOutScope(d)
+			c.emitDiscovered(d, item.Depth+1, false)
+			return
+		}
+		final := normalizeURL(r.Final)
+		c.Mutex.Lock()
+		seen := c.Visited[final]
+		c.Visited[final] = true
+		c.Mutex.Unlock()
+		if seen {
+			c.notifyURL(item, resp, elapsed, -1)
+			return
+		}
+	}
 
-	doc, err := html.Parse(resp.Body)
+	body, truncated, err := c.readBody(resp)
 	if err != nil {
-		log.Printf("Error parsing HTML for URL %s: %v", pageURL, err)
+		errorf("Error reading body for URL %s: %v", pageURL, err)
+		c.recordError(pageURL, err)
+		c.notifyURL(item, resp, elapsed, -1)
+		return
+	}
+	// The decoded length, since the Content-Length header (if any) went
+	// with the Content-Encoding.
+	size := int64(len(body))
+	if truncated {
+		size = -1
+	}
+	c.notifyURL(item, resp, elapsed, size)
+	c.recordPage(resp, body)
+	c.saveResponse(resp, body, truncated)
+	c.scanSecrets(pageURL, body)
+	c.scanEmails(pageURL, resp.Header.Get("Content-Type"), body)
+
+	// A redirect that wasn't followed still tells us where it points, and
+	// Link and Refresh headers can point anywhere on any kind of response.
+	finalURL := resp.Request.URL.String()
+	if loc := resp.Header.Get("Location"); loc != "" && resp.StatusCode >= 300 && resp.StatusCode < 400 {
+		c.discover(ctx, item, pageURL, c.formatURL(finalURL, loc))
+	}
+	for _, link := range headerLinks(resp.Header) {
+		c.discover(ctx, item, pageURL, c.formatURL(finalURL, link))
+	}
+
+	// Only successful responses are worth parsing; anything else has been
+	// recorded with its status above and that's all we do with it.
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		infof("Status %d for URL %s", resp.StatusCode, pageURL)
+		return
+	}
+
+	if c.DedupContent && c.checkDuplicate(finalURL, body) {
 		return
 	}
 
-	urls := c.extractLinks(pageURL, doc)
+	// Sitemaps hand over a site's URLs wholesale. Child sitemaps in an
+	// index are queued like any other URL and parsed when their turn comes.
+	if c.UseSitemaps {
+		if links, ok, err := sitemapLinks(body); ok {
+			if err != nil {
+				errorf("Error parsing sitemap %s: %v", pageURL, err)
+				c.recordError(pageURL, err)
+			}
+			verbosef("Found %d URLs in sitemap %s", len(links), pageURL)
+			for _, link := range links {
+				c.discoverVia(ctx, item, pageURL, c.formatURL(finalURL, link), viaSitemap)
+			}
+			return
+		}
+	}
+
+	// Feeds are XML, where <link> holds a URL; the HTML parser would treat
+	// it as an empty element and lose it.
+	if isFeed(body) {
+		links, err := feedLinks(body)
+		if err != nil {
+			errorf("Error parsing feed %s: %v", pageURL, err)
+			c.recordError(pageURL, err)
+			return
+		}
+		for _, link := range links {
+			c.discoverVia(ctx, item, pageURL, c.formatURL(finalURL, link), viaFeed)
+		}
+		return
+	}
+
+	if isRobotsTxt(finalURL) {
+		for _, link := range robotsLinks(body) {
+			c.discoverVia(ctx, item, pageURL, c.formatURL(finalURL, link), viaRobots)
+		}
+		return
+	}
+
+	// What the server says it sent decides how it is read, not the URL:
+	// plenty of scripts have no extension and plenty of .js URLs are
+	// error pages.
+	if !isHTML(resp.Header.Get("Content-Type"), body) {
+		for _, g := range c.resourceLinks(ctx, resp, body) {
+			for _, u := range g.urls {
+				c.discoverVia(ctx, item, pageURL, u, g.via)
+			}
+		}
+		return
+	}
+
+	// html.Parse only understands UTF-8, so convert using the charset from
+	// the Content-Type header or the page's own <meta charset>.
+	var r io.Reader = bytes.NewReader(body)
+	if utf8Reader, err := charset.NewReader(r, resp.Header.Get("Content-Type")); err == nil {
+		r = utf8Reader
+	} else {
+		verbosef("Could not detect charset for URL %s, assuming UTF-8: %v", pageURL, err)
+	}
+
+	doc, err := html.Parse(r)
+	if err != nil {
+		errorf("Error parsing HTML for URL %s: %v", pageURL, err)
+		c.recordError(pageURL, err)
+		return
+	}
+
+	if c.DedupContent {
+		if canonical := c.canonicalURL(finalURL, doc); canonical != "" && c.checkCanonical(normalizeURL(finalURL), canonical) {
+			// The canonical is still worth knowing about.
+			c.discover(ctx, item, pageURL, canonical)
+			return
+		}
+	}
+
+	// After a redirect, relative links are relative to where the page
+	// ended up, not to the URL that was asked for.
+	if forms := c.extractForms(finalURL, doc); len(forms) > 0 {
+		c.resultMu.Lock()
+		c.result.Forms = append(c.result.Forms, forms...)
+		c.resultMu.Unlock()
+		if c.CrawlGetForms {
+			for _, form := range forms {
+				if u := form.QueryURL(); u != "" {
+					c.discoverVia(ctx, item, pageURL, u, viaForm)
+				}
+			}
+		}
+	}
+
+	// Links found through <link> are tagged with their rel. Manifests are
+	// read before anything is queued, so no worker crawls one as a page
+	// first.
+	rels := make(map[string]string)
+	for _, l := range c.linkRels(finalURL, doc) {
+		rels[l.URL] = l.Rel
+		if l.is("manifest") {
+			manifestURL := normalizeURL(l.URL)
+			for _, u := range c.manifestLinks(WithReferer(ctx, pageURL), manifestURL) {
+				c.discoverVia(ctx, item, manifestURL, u, viaManifest)
+			}
+		}
+	}
+	urls := c.extractLinks(finalURL, doc)
 	for _, u := range urls {
-		if c.isValidURL(u) {
-			if c.isInScope(u) {
-				log.Printf("In-scope URL found: %s", u)
-				inScopeCh <- "In-scope: " + u
-				c.Queue <- u
-				c.WG.Add(1)
-			} else {
-				log.Printf("Out-of-scope URL found: %s", u)
-				outScopeCh <- "Out-Of-Scope: " + u
+		c.discoverVia(ctx, item, pageURL, u, rels[u])
+	}
+	comments, noscript := c.extractHidden(finalURL, doc)
+	for _, u := range comments {
+		c.discoverVia(ctx, item, pageURL, u, viaComment)
+	}
+	for _, u := range noscript {
+		c.discoverVia(ctx, item, pageURL, u, viaNoscript)
+	}
+}
+
+// discover handles a URL found on pageURL while crawling item: in-scope
+// URLs are queued, out-of-scope ones recorded, and code files scanned.
+func (c *Crawler) discover(ctx context.Context, item QueueItem, pageURL, u string) {
+	c.discoverVia(ctx, item, pageURL, u, "")
+}
+
+// discoverVia is discover for URLs found other than as ordinary links,
+// tagging them with via.
+func (c *Crawler) discoverVia(ctx context.Context, item QueueItem, pageURL, u, via string) {
+	// Pseudo-URLs are dropped before anything else looks at them. The
+	// content of data: URIs can be searched for links instead.
+	if scheme := urlScheme(u); ignoredSchemes[scheme] {
+		if scheme == "data" && c.DataURIs {
+			for _, link := range c.dataURILinks(pageURL, u) {
+				c.discoverVia(ctx, item, pageURL, link, viaDataURI)
 			}
-		} else {
-			log.Printf("Invalid URL found: %s", u)
 		}
-		if isCodeFile(u) {
-			c.extractURLsFromScript(u, inScopeCh, outScopeCh)
+		return
+	}
+	u = normalizeURL(u)
+	c.checkCloud(u, pageURL)
+	if c.isValidURL(u) {
+		c.recordLink(pageURL, u)
+		d := Discovery{URL: u, Source: pageURL, DiscoveredAt: time.Now(), Via: via}
+		if c.isInScope(u) {
+			verbosef("In-scope URL found: %s", u)
+			c.recordInScope(d)
+			// API descriptions are read here, before they are queued, so no
+			// worker crawls one as a page; the endpoints are what matter.
+			var endpoints []string
+			if isAPISpec(u) {
+				endpoints = c.apiSpecLinks(WithReferer(ctx, pageURL), u)
+			}
+			// processURL reads whatever the URL turns out to be, so in-scope
+			// code files aren't fetched a second time below.
+			c.enqueue(QueueItem{URL: u, Source: pageURL, Depth: item.Depth + 1, DiscoveredAt: d.DiscoveredAt})
+			for _, endpoint := range endpoints {
+				c.discoverVia(ctx, item, u, endpoint, viaOpenAPI)
+			}
+			return
+		}
+		verbosef("Out-of-scope URL found: %s", u)
+		c.recordOutScope(d)
+		c.emitDiscovered(d, item.Depth+1, false)
+	} else if otherSchemeFile(u) != "" {
+		verbosef("Non-HTTP URL found: %s", u)
+		c.recordOtherScheme(Discovery{URL: u, Source: pageURL, DiscoveredAt: time.Now()})
+		if urlScheme(u) == "mailto" {
+			c.recordEmails(pageURL, mailtoAddresses(u))
 		}
+		return
+	} else {
+		verbosef("Invalid URL found: %s", u)
+	}
+	if c.isCodeFile(u) {
+		c.extractURLsFromScript(WithReferer(ctx, pageURL), u, item.Depth+1)
 	}
 }
 
-func (c *Crawler) CrawlWithChrome(startURL string, inScopeCh, outScopeCh chan<- string) {
+func (c *Crawler) CrawlWithChrome(parent context.Context, startURL string) {
 
-	ctx, cancel := chromedp.NewContext(context.Background())
+	opts := append(chromedp.DefaultExecAllocatorOptions[:], c.ChromeOptions...)
+	allocCtx, cancelAlloc := chromedp.NewExecAllocator(parent, opts...)
+	defer cancelAlloc()
+	ctx, cancel := chromedp.NewContext(allocCtx)
 	defer cancel()
 
 	var wg sync.WaitGroup
@@ -133,7 +874,7 @@ func (c *Crawler) CrawlWithChrome(startURL string, inScopeCh, outScopeCh chan<-
 			chromedp.Navigate(startURL),
 			chromedp.Sleep(5*time.Second),
 		); err != nil {
-			log.Printf("Error navigating to URL %s with Chrome: %v", startURL, err)
+			errorf("Error navigating to URL %s with Chrome: %v", startURL, err)
 		}
 		close(ch)
 	}()
@@ -142,14 +883,19 @@ func (c *Crawler) CrawlWithChrome(startURL string, inScopeCh, outScopeCh chan<-
 	go func() {
 		defer wg.Done()
 		for req := range ch {
-			log.Printf("URL found via Chrome: %s", req)
+			req = normalizeURL(req)
+			verbosef("URL found via Chrome: %s", req)
 			if c.isValidURL(req) {
+				c.recordLink(startURL, req)
+				d := Discovery{URL: req, Source: startURL, DiscoveredAt: time.Now()}
 				if c.isInScope(req) {
-					log.Printf("In-scope URL found via Chrome: %s", req)
-					inScopeCh <- "In-scope: " + req
+					verbosef("In-scope URL found via Chrome: %s", req)
+					c.recordInScope(d)
+					c.emitDiscovered(d, 1, true)
 				} else {
-					log.Printf("Out-of-scope URL found via Chrome: %s", req)
-					outScopeCh <- "Out-Of-Scope: " + req
+					verbosef("Out-of-scope URL found via Chrome: %s", req)
+					c.recordOutScope(d)
+					c.emitDiscovered(d, 1, false)
 				}
 			}
 		}
@@ -164,16 +910,15 @@ func (c *Crawler) extractLinks(base string, n *html.Node) []string {
 		switch n.Data {
 		case "a", "link", "img", "iframe", "frame", "embed", "script", "source", "track", "video", "audio", "applet", "object", "area", "base", "input", "form":
 			for _, a := range n.Attr {
-				if a.Key == "href" || a.Key == "src" || a.Key == "data" || a.Key == "action" {
+				if a.Key == "href" || a.Key == "src" || a.Key == "data" || a.Key == "action" || a.Key == "formaction" {
 					absoluteURL := c.formatURL(base, a.Val)
 					urls = append(urls, absoluteURL)
 				}
 			}
 		case "meta":
-			for _, a := range n.Attr {
-				if a.Key == "content" && (strings.Contains(a.Val, "url=") || strings.Contains(a.Val, "URL=")) {
-					absoluteURL := c.formatURL(base, strings.Split(a.Val, "=")[1])
-					urls = append(urls, absoluteURL)
+			if strings.EqualFold(attr(n, "http-equiv"), "refresh") {
+				if target := parseRefresh(attr(n, "content")); target != "" {
+					urls = append(urls, c.formatURL(base, target))
 				}
 			}
 		case "button":
@@ -190,6 +935,14 @@ func (c *Crawler) extractLinks(base string, n *html.Node) []string {
 					urls = append(urls, absoluteURL)
 				}
 			}
+		case "param":
+			// Plugins take their resource from <object><param> as often
+			// as from the object's own data attribute.
+			if paramURLNames[strings.ToLower(attr(n, "name"))] {
+				if v := strings.TrimSpace(attr(n, "value")); v != "" {
+					urls = append(urls, c.formatURL(base, v))
+				}
+			}
 		case "command":
 			for _, a := range n.Attr {
 				if a.Key == "icon" {
@@ -197,6 +950,15 @@ func (c *Crawler) extractLinks(base string, n *html.Node) []string {
 					urls = append(urls, absoluteURL)
 				}
 			}
+		case "image", "use", "feImage":
+			// SVG references. html.Parse splits xlink:href into Namespace
+			// "xlink" and Key "href", so both spellings end up here.
+			// Fragment-only references point inside the document.
+			for _, a := range n.Attr {
+				if a.Key == "href" && (a.Namespace == "" || a.Namespace == "xlink") && !strings.HasPrefix(strings.TrimSpace(a.Val), "#") {
+					urls = append(urls, c.formatURL(base, strings.TrimSpace(a.Val)))
+				}
+			}
 		case "data":
 			for _, a := range n.Attr {
 				if a.Key == "value" {
@@ -204,6 +966,83 @@ func (c *Crawler) extractLinks(base string, n *html.Node) []string {
 					urls = append(urls, absoluteURL)
 				}
 			}
+		case "style":
+			for child := n.FirstChild; child != nil; child = child.NextSibling {
+				if child.Type == html.TextNode {
+					for _, link := range cssLinks(child.Data) {
+						urls = append(urls, c.formatURL(base, link))
+					}
+				}
+			}
+		}
+
+		// Responsive images, video posters and lazy loaders can sit on
+		// any element.
+		for _, a := range n.Attr {
+			switch {
+			case a.Key == "srcset" || a.Key == "imagesrcset" || c.isLazyAttribute(a.Key) && strings.HasSuffix(a.Key, "srcset"):
+				for _, u := range parseSrcset(a.Val) {
+					urls = append(urls, c.formatURL(base, u))
+				}
+			case a.Key == "poster" || c.isLazyAttribute(a.Key):
+				// Framework attributes like ng-href can hold a template
+				// rather than a URL.
+				if v := strings.TrimSpace(a.Val); v != "" && !strings.Contains(v, "{{") {
+					urls = append(urls, c.formatURL(base, v))
+				}
+			case c.DataAttrs && strings.HasPrefix(a.Key, "data-"):
+				// Apps keep routes, and sometimes whole JSON configs, in
+				// data attributes. Only values that look like links count.
+				v := strings.TrimSpace(a.Val)
+				links := []string{v}
+				if strings.HasPrefix(v, "{") || strings.HasPrefix(v, "[") {
+					links = jsonLinks([]byte(c.capInline(v)))
+				} else if !looksLikeLink(v) && !(strings.Contains(v, "/") && isScriptPath(v)) {
+					links = nil
+				}
+				for _, link := range links {
+					urls = append(urls, c.formatURL(base, link))
+				}
+			case a.Key == "style":
+				for _, link := range cssLinks(a.Val) {
+					urls = append(urls, c.formatURL(base, link))
+				}
+			case strings.HasPrefix(a.Key, "on"):
+				for _, link := range inlineScriptLinks(c.capInline(a.Val)) {
+					urls = append(urls, c.formatURL(base, link))
+				}
+			}
+		}
+
+		// Single page apps do much of their routing from inline scripts.
+		if n.Data == "script" {
+			isData := isJSON(attr(n, "type"), nil)
+			for child := n.FirstChild; child != nil; child = child.NextSibling {
+				if child.Type != html.TextNode {
+					continue
+				}
+				links := inlineScriptLinks(c.capInline(child.Data))
+				if isData {
+					links = jsonLinks([]byte(c.capInline(child.Data)))
+				}
+				for _, link := range links {
+					urls = append(urls, c.formatURL(base, link))
+				}
+			}
+		}
+
+		// A srcdoc holds a whole document, and <noframes> the fallback
+		// for browsers without frames, both as unparsed text. Relative
+		// links in either resolve against the page they sit in.
+		switch n.Data {
+		case "iframe":
+			if srcdoc := attr(n, "srcdoc"); srcdoc != "" {
+				if doc, err := html.Parse(strings.NewReader(c.capInline(srcdoc))); err == nil {
+					urls = append(urls, c.extractLinks(base, doc)...)
+				}
+			}
+		case "noframes":
+			urls = append(urls, c.fragmentLinks(base, textContent(n))...)
 		}
 	}
 
@@ -213,100 +1052,436 @@ func (c *Crawler) extractLinks(base string, n *html.Node) []string {
 	return urls
 }
 
-func isCodeFile(u string) bool {
-	codeExtensions := []string{
-		".js", ".jsp", ".xml", ".html", ".htm", ".php", ".asp", ".aspx", ".css", ".json",
-		".txt", ".md", ".yaml", ".csv", ".doc", ".docx", ".pdf", ".ppt", ".pptx", ".xls",
-		".xlsx", ".ts", ".py", ".rb", ".java", ".c", ".h", ".cs", ".swift", ".kt",
-		".pl", ".sh", ".bat", ".go"}
+// capInline cuts inline script text down to MaxBodySize, so a page that
+// embeds a huge bundle can't cost more to scan than fetching it would.
+func (c *Crawler) capInline(s string) string {
+	if c.MaxBodySize > 0 && int64(len(s)) > c.MaxBodySize {
+		return s[:c.MaxBodySize]
+	}
+	return s
+}
 
-	for _, ext := range codeExtensions {
-		if strings.HasSuffix(u, ext) {
+// fragmentLinks parses text as the contents of a <body> and extracts links
+// from it.
+func (c *Crawler) fragmentLinks(base, text string) []string {
+	var urls []string
+	body := &html.Node{Type: html.ElementNode, Data: "body", DataAtom: atom.Body}
+	nodes, err := html.ParseFragment(strings.NewReader(c.capInline(text)), body)
+	if err != nil {
+		return nil
+	}
+	for _, node := range nodes {
+		urls = append(urls, c.extractLinks(base, node)...)
+	}
+	return urls
+}
+
+// paramURLNames are the <param> names whose value is a URL in Flash, Java
+// and media player embeds.
+var paramURLNames = map[string]bool{
+	"movie":    true,
+	"src":      true,
+	"url":      true,
+	"filename": true,
+	"data":     true,
+	"href":     true,
+}
+
+// isHTML reports whether a response is an HTML page, going by its
+// Content-Type or, if it has none, by sniffing the body. SVG counts too:
+// its links are elements and attributes the HTML parser already handles.
+func isHTML(contentType string, body []byte) bool {
+	if contentType == "" {
+		contentType = http.DetectContentType(body)
+	}
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return true
+	}
+	return mediaType == "text/html" || mediaType == "application/xhtml+xml" || mediaType == "image/svg+xml"
+}
+
+func (c *Crawler) isLazyAttribute(key string) bool {
+	for _, attr := range c.LazyAttributes {
+		if key == attr {
 			return true
 		}
 	}
 	return false
 }
 
-func (c *Crawler) extractURLsFromScript(scriptURL string, inScopeCh, outScopeCh chan<- string) {
-	resp, err := c.fetchURL(scriptURL)
-	if err != nil || resp.StatusCode != http.StatusOK {
-		log.Printf("Error fetching script URL %s: %v", scriptURL, err)
+// defaultCodeExtensions are the file types fetched and searched for URLs
+// wherever they are linked from, in scope or not.
+var defaultCodeExtensions = []string{
+	".js", ".jsp", ".xml", ".html", ".htm", ".php", ".asp", ".aspx", ".css", ".json",
+	".txt", ".md", ".yaml", ".csv", ".doc", ".docx", ".pdf", ".ppt", ".pptx", ".xls",
+	".xlsx", ".ts", ".py", ".rb", ".java", ".c", ".h", ".cs", ".swift", ".kt",
+	".pl", ".sh", ".bat", ".go"}
+
+// isCodeFile reports whether the extension of u's path, ignoring case, is
+// one of CodeExtensions. The query string and fragment don't count, so
+// /app.JS?v=2 is a code file and /download?file=report.pdf isn't.
+func (c *Crawler) isCodeFile(u string) bool {
+	parsed, err := url.Parse(u)
+	if err != nil {
+		return false
+	}
+	ext := strings.ToLower(path.Ext(parsed.Path))
+	return ext != "" && c.CodeExtensions[ext]
+}
+
+// parseCodeExtensions applies a -code-exts list such as "+.vue,-.pdf,mjs"
+// to the default extensions: entries starting with "-" are removed, any
+// others added. The leading dot is optional.
+func parseCodeExtensions(spec string) map[string]bool {
+	exts := make(map[string]bool)
+	for _, ext := range defaultCodeExtensions {
+		exts[ext] = true
+	}
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.ToLower(strings.TrimSpace(entry))
+		remove := strings.HasPrefix(entry, "-")
+		entry = strings.TrimLeft(entry, "+-")
+		if entry == "" {
+			continue
+		}
+		if !strings.HasPrefix(entry, ".") {
+			entry = "." + entry
+		}
+		if remove {
+			delete(exts, entry)
+		} else {
+			exts[entry] = true
+		}
+	}
+	return exts
+}
+
+func (c *Crawler) extractURLsFromScript(ctx context.Context, scriptURL string, depth int) {
+	if c.overBudget() {
+		return
+	}
+	// A script linked from every page is still only fetched once.
+	c.Mutex.Lock()
+	fetched := c.Visited[scriptURL]
+	c.Visited[scriptURL] = true
+	c.Mutex.Unlock()
+	if fetched {
+		return
+	}
+	if resp, _, ok := c.headOnly(ctx, scriptURL); ok {
+		c.Stats.Pages.Add(1)
+		if c.isInScope(scriptURL) {
+			c.recordPage(resp, nil)
+		}
+		return
+	}
+	resp, _, err := c.fetchURL(ctx, scriptURL)
+	if err != nil {
+		errorf("Error fetching script URL %s: %v", scriptURL, err)
+		c.recordError(scriptURL, err)
 		return
 	}
 	defer resp.Body.Close()
+	c.Stats.Pages.Add(1)
+	if resp.StatusCode != http.StatusOK {
+		infof("Status %d for script URL %s", resp.StatusCode, scriptURL)
+		if c.isInScope(scriptURL) {
+			c.recordPage(resp, nil)
+		}
+		return
+	}
 
-	bodyBytes, err := io.ReadAll(resp.Body)
+	bodyBytes, truncated, err := c.readBody(resp)
 	if err != nil {
-		log.Printf("Error reading script body for URL %s: %v", scriptURL, err)
+		errorf("Error reading script body for URL %s: %v", scriptURL, err)
 		return
 	}
-	body := string(bodyBytes)
+	if c.isInScope(scriptURL) {
+		c.recordPage(resp, bodyBytes)
+	}
+	c.saveResponse(resp, bodyBytes, truncated)
+	c.scanSecrets(scriptURL, bodyBytes)
+	c.scanEmails(scriptURL, resp.Header.Get("Content-Type"), bodyBytes)
 
-	urlRegex := regexp.MustCompile(`http[s]?://[^\s"']+`)
-	urls := urlRegex.FindAllString(body, -1)
+	// Scripts and stylesheets advertise preloads and source maps in Link
+	// headers as well.
+	var headers []string
+	for _, link := range headerLinks(resp.Header) {
+		if u := c.formatURL(resp.Request.URL.String(), link); c.isValidURL(u) {
+			headers = append(headers, u)
+		}
+	}
 
 	seen := make(map[string]bool)
-	for _, u := range urls {
-		if seen[u] {
-			continue
+	report := func(urls []string, via string) {
+		for _, u := range urls {
+			u = normalizeURL(u)
+			if seen[u] {
+				continue
+			}
+			seen[u] = true
+
+			c.checkCloud(u, scriptURL)
+			verbosef("URL found in script: %s", u)
+			c.recordLink(scriptURL, u)
+			d := Discovery{URL: u, Source: scriptURL, DiscoveredAt: time.Now(), Via: via}
+			if c.isInScope(u) {
+				verbosef("In-scope URL found: %s", u)
+				c.recordInScope(d)
+				c.emitDiscovered(d, depth+1, true)
+			} else {
+				verbosef("Out-of-scope URL found: %s", u)
+				c.recordOutScope(d)
+				c.emitDiscovered(d, depth+1, false)
+			}
 		}
-		seen[u] = true
+	}
+	report(headers, "")
+	for _, g := range c.resourceLinks(ctx, resp, bodyBytes) {
+		report(g.urls, g.via)
+	}
+}
 
-		log.Printf("URL found in script: %s", u)
-		if c.isInScope(u) {
-			log.Printf("In-scope URL found: %s", u)
-			inScopeCh <- "In-scope: " + u
-		} else {
-			log.Printf("Out-of-scope URL found: %s", u)
-			outScopeCh <- "Out-Of-Scope: " + u
+// linkGroup is a batch of URLs found the same way, tagged with via.
+type linkGroup struct {
+	via  string
+	urls []string
+}
+
+// resourceLinks extracts the absolute URLs from any response that isn't
+// an HTML page: scripts, stylesheets, JSON, YAML and XML files, PDFs and
+// plain text. The extractor is picked by Content-Type, or by the URL's
+// extension when the server doesn't say. Plain links come first, then
+// the guessed relative paths, source map sources and host names.
+func (c *Crawler) resourceLinks(ctx context.Context, resp *http.Response, bodyBytes []byte) []linkGroup {
+	resourceURL := resp.Request.URL.String()
+	contentType := resp.Header.Get("Content-Type")
+	body := string(bodyBytes)
+
+	var urls []string
+	var hosts []string
+	urlRegex := regexp.MustCompile(`http[s]?://[^\s"']+`)
+	if links, ok := c.parsePDF(resourceURL, bodyBytes); ok {
+		for _, link := range links {
+			if u := c.formatURL(resourceURL, link); c.isValidURL(u) {
+				urls = append(urls, u)
+			}
+		}
+	} else if values, ok := configStrings(contentType, resourceURL, bodyBytes); ok {
+		// JSON, YAML and XML files are parsed value by value, as they
+		// often give paths relative to the API's own host and host names
+		// without a scheme, and the regex would run on into XML markup.
+		for _, v := range values {
+			switch {
+			case looksLikeLink(v):
+				if u := c.formatURL(resourceURL, v); c.isValidURL(u) {
+					urls = append(urls, u)
+				}
+			case isHostname(v):
+				hosts = append(hosts, "https://"+strings.ToLower(v)+"/")
+			default:
+				urls = append(urls, urlRegex.FindAllString(v, -1)...)
+			}
+		}
+	} else {
+		urls = urlRegex.FindAllString(body, -1)
+	}
+	// Stylesheets mostly use relative url(...) references, which the regex
+	// above can't see.
+	if isCSS(contentType, resourceURL) {
+		for _, link := range cssLinks(body) {
+			if u := c.formatURL(resourceURL, link); c.isValidURL(u) {
+				urls = append(urls, u)
+			}
 		}
 	}
+
+	// Bundles mostly talk to their own API through relative paths. They
+	// are only guesses, so they come last and are tagged as such.
+	var relative []string
+	if isJavaScript(contentType, resourceURL) {
+		origin := &url.URL{Scheme: resp.Request.URL.Scheme, Host: resp.Request.URL.Host, Path: "/"}
+		for _, path := range scriptRelativePaths(body) {
+			relative = append(relative, c.formatURL(origin.String(), path))
+		}
+	}
+
+	var mapped []string
+	if isJavaScript(contentType, resourceURL) || isCSS(contentType, resourceURL) {
+		mapped = c.sourceMapLinks(ctx, resp, body)
+	}
+
+	// s3:// and gs:// references aren't links, but are worth reporting.
+	for _, u := range bucketSchemeRegex.FindAllString(body, -1) {
+		c.checkCloud(u, resourceURL)
+	}
+
+	return []linkGroup{
+		{"", urls},
+		{viaJSRelative, relative},
+		{viaSourceMap, mapped},
+		{viaHostname, hosts},
+	}
 }
 
-func (c *Crawler) fetchURL(pageURL string) (*http.Response, error) {
-	var redirectURL string
-	client := &http.Client{
-		CheckRedirect: func(req *http.Request, via []*http.Request) error {
-			redirectURL = req.URL.String()
-			log.Printf("Redirected from %s to %s", via[len(via)-1].URL, redirectURL)
-			return nil
-		},
+// overBudget reports whether MaxBytes has been downloaded already.
+func (c *Crawler) overBudget() bool {
+	if c.MaxBytes <= 0 || c.Stats.Bytes.Load() < c.MaxBytes {
+		return false
+	}
+	if c.Stats.ByteLimitReached.CompareAndSwap(false, true) {
+		infof("Downloaded %d bytes, the -max-bytes limit; finishing the crawl", c.Stats.Bytes.Load())
 	}
+	return true
+}
 
-	req, err := http.NewRequest("GET", pageURL, nil)
-	if err != nil {
-		log.Printf("Error creating request for URL %s: %v", pageURL, err)
-		return nil, err
+// readBody reads at most MaxBodySize bytes of the response body. truncated
+// reports whether the body was longer than that.
+func (c *Crawler) readBody(resp *http.Response) (body []byte, truncated bool, err error) {
+	defer func() { c.Stats.Bytes.Add(int64(len(body))) }()
+	var r io.Reader = &meteredReader{r: resp.Body, limiter: c.bandwidth, meter: &c.Stats.Throughput}
+	if c.MaxBodySize <= 0 {
+		body, err = io.ReadAll(r)
+		return body, false, err
+	}
+	body, err = io.ReadAll(io.LimitReader(r, c.MaxBodySize+1))
+	if int64(len(body)) > c.MaxBodySize {
+		return body[:c.MaxBodySize], true, err
 	}
+	return body, false, err
+}
 
-	req.Header.Set("User-Agent", "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/58.0.3029.110 Safari/537.3")
-	resp, err := client.Do(req)
+func (c *Crawler) saveResponse(resp *http.Response, body []byte, truncated bool) {
+	if c.Saver == nil {
+		return
+	}
+	u := resp.Request.URL.String()
+	if truncated {
+		infof("Not saving %s: body exceeds %d bytes", u, c.MaxBodySize)
+		return
+	}
+	if err := c.Saver.Save(u, resp.StatusCode, resp.Header.Get("Content-Type"), body); err != nil {
+		errorf("Could not save response for %s: %v", u, err)
+	}
+}
 
-	if err != nil && redirectURL != "" {
+// fetchURL fetches pageURL and reports how long it took to get the
+// response headers.
+func (c *Crawler) fetchURL(ctx context.Context, pageURL string) (*http.Response, time.Duration, error) {
+	if c.pacer != nil {
+		if err := c.pacer.wait(ctx); err != nil {
+			return nil, 0, err
+		}
+	}
+	start := time.Now()
+	resp, err := c.Fetcher.Fetch(ctx, pageURL)
+	elapsed := time.Since(start)
+	c.Stats.RequestDurations.Observe(elapsed)
+	if c.concurrency != nil {
+		failed := err != nil || resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500
+		c.concurrency.observe(elapsed, failed)
+	}
+	return resp, elapsed, err
+}
 
-		log.Printf("Error fetching URL %s: %v, but redirected to %s", pageURL, err, redirectURL)
-	} else if err != nil {
+// headOnly sends a HEAD request for u when HeadFirst is set and reports
+// whether its response is all that's needed, i.e. the body would not be
+// searched for links. Errors and non-2xx responses, including servers that
+// don't support HEAD, leave it to the usual GET.
+func (c *Crawler) headOnly(ctx context.Context, u string) (*http.Response, time.Duration, bool) {
+	hf, ok := c.Fetcher.(HeadFetcher)
+	if !c.HeadFirst || !ok {
+		return nil, 0, false
+	}
+	if c.pacer != nil && c.pacer.wait(ctx) != nil {
+		return nil, 0, false
+	}
+	start := time.Now()
+	resp, err := hf.Head(ctx, u)
+	elapsed := time.Since(start)
+	if err != nil {
+		verbosef("HEAD %s failed, falling back to GET: %v", u, err)
+		return nil, 0, false
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		verbosef("HEAD %s returned %d, falling back to GET", u, resp.StatusCode)
+		return nil, 0, false
+	}
+	contentType := resp.Header.Get("Content-Type")
+	if c.extractsFrom(contentType) {
+		return nil, 0, false
+	}
+	c.Stats.RequestDurations.Observe(elapsed)
+	verbosef("Skipping download of %s (%s, %d bytes)", u, contentType, resp.ContentLength)
+	return resp, elapsed, true
+}
 
-		log.Printf("Error fetching URL %s: %v", pageURL, err)
+// extractsFrom reports whether responses of the given content type are
+// searched for links. An unknown type might be anything, so it counts.
+func (c *Crawler) extractsFrom(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return true
 	}
+	switch {
+	case mediaType == "application/pdf":
+		return c.ParsePDF
+	case mediaType == "application/gzip" || mediaType == "application/x-gzip":
+		// It could be a compressed sitemap.
+		return c.UseSitemaps
+	case strings.HasPrefix(mediaType, "text/"),
+		strings.HasSuffix(mediaType, "xml"),
+		strings.HasSuffix(mediaType, "json"),
+		strings.HasSuffix(mediaType, "javascript"):
+		return true
+	}
+	return false
+}
 
-	if err == nil && resp.StatusCode == http.StatusOK {
-		return resp, nil
+// fetchResource downloads a file the crawler reads for links of its own,
+// such as a source map or a web app manifest, once per crawl and within
+// the usual body size limit. kind names it in log messages. It returns nil
+// if the file can't be fetched or is too large to parse.
+func (c *Crawler) fetchResource(ctx context.Context, resourceURL, kind string) []byte {
+	if c.overBudget() {
+		return nil
+	}
+	c.Mutex.Lock()
+	seen := c.Visited[resourceURL]
+	c.Visited[resourceURL] = true
+	c.Mutex.Unlock()
+	if seen {
+		return nil
 	}
 
-	u, _ := url.Parse(pageURL)
-	if u.Scheme == "http" {
-		u.Scheme = "https"
-	} else {
-		u.Scheme = "http"
+	resp, _, err := c.fetchURL(ctx, resourceURL)
+	if err != nil {
+		errorf("Error fetching %s %s: %v", kind, resourceURL, err)
+		c.recordError(resourceURL, err)
+		return nil
+	}
+	defer resp.Body.Close()
+	c.Stats.Pages.Add(1)
+	if resp.StatusCode != http.StatusOK {
+		infof("Status %d for %s %s", resp.StatusCode, kind, resourceURL)
+		c.recordPage(resp, nil)
+		return nil
 	}
-	req.URL = u
-	resp, err = client.Do(req)
+	body, truncated, err := c.readBody(resp)
 	if err != nil {
-		log.Printf("Error fetching URL %s: %v", u, err)
+		errorf("Error reading %s %s: %v", kind, resourceURL, err)
+		return nil
 	}
-	return resp, err
+	c.recordPage(resp, body)
+	c.saveResponse(resp, body, truncated)
+	c.scanSecrets(resourceURL, body)
+	c.scanEmails(resourceURL, resp.Header.Get("Content-Type"), body)
+	if truncated {
+		infof("The %s %s is larger than -max-body-size, skipping it", kind, resourceURL)
+		return nil
+	}
+	return body
 }
 
 func (c *Crawler) formatURL(base, href string) string {
@@ -333,78 +1508,149 @@ func (c *Crawler) isInScope(u string) bool {
 		return false
 	}
 
-	for _, scope := range c.InScope {
-		if strings.HasSuffix(parsedURL.Host, scope) {
+	for _, rule := range c.inScopeRules {
+		if rule.match(parsedURL) {
 			return true
 		}
 	}
 
-	for _, scope := range c.OutScope {
-		if strings.HasSuffix(parsedURL.Host, scope) {
+	for _, rule := range c.outScopeRules {
+		if rule.match(parsedURL) {
 			return false
 		}
 	}
 
-	return len(c.InScope) == 0
+	return len(c.inScopeRules) == 0
 }
 
-func (c *Crawler) writeToFiles(inScopeFile, outScopeFile string, inScopeCh, outScopeCh <-chan string) {
+func (c *Crawler) writeToFiles(inScopeFile, outScopeFile string, res *Result, timestamps bool) {
 	inScope, err := os.Create(inScopeFile)
 	if err != nil {
-		log.Fatalf("Could not create file %s: %v", inScopeFile, err)
+		fatalf("Could not create file %s: %v", inScopeFile, err)
 	}
 	defer inScope.Close()
 
 	outScope, err := os.Create(outScopeFile)
 	if err != nil {
-		log.Fatalf("Could not create file %s: %v", outScopeFile, err)
+		fatalf("Could not create file %s: %v", outScopeFile, err)
 	}
 	defer outScope.Close()
 
 	inScope.WriteString("--IN SCOPE URLS:---\n")
 	outScope.WriteString("--OUT OF SCOPE URLS:---\n")
 
-	var wg sync.WaitGroup
-	wg.Add(2)
+	for _, d := range res.InScope {
+		_, err := inScope.WriteString(timestampPrefix(d, timestamps) + "In-scope: " + d.URL + viaSuffix(d) + "\n")
+		if err != nil {
+			errorf("Could not write URL %s to file: %v", d.URL, err)
+		}
+	}
 
-	go func() {
-		defer wg.Done()
-		for u := range inScopeCh {
-			_, err := inScope.WriteString(u + "\n")
-			if err != nil {
-				log.Printf("Could not write URL %s to file: %v", u, err)
-			}
+	for _, d := range res.OutScope {
+		_, err := outScope.WriteString(timestampPrefix(d, timestamps) + "Out-Of-Scope: " + d.URL + viaSuffix(d) + "\n")
+		if err != nil {
+			errorf("Could not write URL %s to file: %v", d.URL, err)
 		}
-	}()
+	}
+}
 
-	go func() {
-		defer wg.Done()
-		for u := range outScopeCh {
-			_, err := outScope.WriteString(u + "\n")
-			if err != nil {
-				log.Printf("Could not write URL %s to file: %v", u, err)
-			}
+func viaSuffix(d Discovery) string {
+	if d.Via == "" {
+		return ""
+	}
+	return " (" + d.Via + ")"
+}
+
+func timestampPrefix(d Discovery, timestamps bool) string {
+	if !timestamps {
+		return ""
+	}
+	return d.DiscoveredAt.Format(time.RFC3339) + " "
+}
+
+// writeNon200 lists every in-scope URL that came back with a status other
+// than 200, one "<status> <url>" per line. If every page was a 200, no file
+// is written.
+func writeNon200(filename string, pages []Page) error {
+	var non200 []Page
+	for _, p := range pages {
+		if p.StatusCode != http.StatusOK {
+			non200 = append(non200, p)
 		}
-	}()
+	}
+	if len(non200) == 0 {
+		return nil
+	}
 
-	wg.Wait()
+	f, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	f.WriteString("--NON-200 IN SCOPE URLS:---\n")
+	for _, p := range non200 {
+		if _, err := fmt.Fprintf(f, "%d %s\n", p.StatusCode, p.URL); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
-func main() {
-	urlPtr := flag.String("url", "", "URL to start crawling from")
-	outputPtr := flag.String("output", "output.txt", "Output file to write URLs to")
-	inScopePtr := flag.String("inscope", "", "Comma-separated list of in-scope base URLs")
-	outScopePtr := flag.String("outscope", "", "Comma-separated list of out-of-scope base URLs")
+// writeErrors lists every URL that could not be fetched or read, one
+// "<url> <error>" per line, so timeouts and the like can be retried.
+func writeErrors(filename string, errs []FetchError) error {
+	f, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
 
+	f.WriteString("--FAILED URLS:---\n")
+	for _, e := range errs {
+		if _, err := fmt.Fprintf(f, "%s %v\n", e.URL, e.Err); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func main() {
+	var opts options
+	opts.register(flag.CommandLine)
 	flag.Parse()
 
-	if *urlPtr == "" {
-		log.Fatal("Provide a starting URL using -url flag")
+	if opts.Config != "" {
+		if err := loadConfig(opts.Config, flag.CommandLine); err != nil {
+			fatalf("Could not load config: %v", err)
+		}
+	}
+	if err := setLogFormat(opts.LogFormat); err != nil {
+		fatalf("Invalid -log-format: %v", err)
+	}
+	switch {
+	case opts.Verbose && opts.Quiet:
+		fatalf("Use either -v or -q, not both")
+	case opts.Verbose:
+		verbosity = levelVerbose
+	case opts.Quiet:
+		verbosity = levelQuiet
 	}
 
-	inScope := strings.Split(*inScopePtr, ",")
-	outScope := strings.Split(*outScopePtr, ",")
+	job, err := newCrawlJob(&opts)
+	if err != nil {
+		fatalf("%v", err)
+	}
+	defer job.Close()
 
-	crawler := NewCrawler(inScope, outScope)
-	crawler.Crawl(*urlPtr, *outputPtr)
+	// Ctrl-C stops the crawl but still writes out what was found and,
+	// with -state, what is left to do.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	res, err := job.Run(ctx)
+	if err != nil {
+		errorf("Crawl stopped early: %v", err)
+	}
+	job.writeOutputs(res)
+	infof("SCAN FINISHED")
 }
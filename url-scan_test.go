@@ -0,0 +1,165 @@
+package main
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"golang.org/x/net/html"
+)
+
+// pageLinks returns every link extractLinks finds in page, sorted.
+func pageLinks(t *testing.T, c *Crawler, base, page string) []string {
+	t.Helper()
+	doc, err := html.Parse(strings.NewReader(page))
+	if err != nil {
+		t.Fatal(err)
+	}
+	links := c.extractLinks(base, doc)
+	sort.Strings(links)
+	return links
+}
+
+func TestIsCodeFile(t *testing.T) {
+	c := NewCrawler(nil, nil)
+	tests := []struct {
+		url  string
+		want bool
+	}{
+		{"https://example.com/static/app.js", true},
+		{"https://example.com/static/app.js?v=3", true},
+		{"https://example.com/static/app.js#L10", true},
+		{"https://example.com/static/app.js?v=3#top", true},
+		{"https://example.com/STATIC/APP.JS", true},
+		{"https://example.com/Report.PDF", true},
+		{"https://example.com/index.php?page=a.png", true},
+		{"https://example.com/image.png?name=x.js", false},
+		{"https://example.com/page#section.js", false},
+		{"https://example.com/download", false},
+		{"https://example.com/dir.js/", false},
+		{"https://example.com/", false},
+		{"https://example.com", false},
+		{"https://example.com/archive.tar.gz", false},
+	}
+	for _, tt := range tests {
+		if got := c.isCodeFile(tt.url); got != tt.want {
+			t.Errorf("isCodeFile(%q) = %v, want %v", tt.url, got, tt.want)
+		}
+	}
+}
+
+func TestParseCodeExtensions(t *testing.T) {
+	tests := []struct {
+		spec    string
+		present []string
+		absent  []string
+	}{
+		{"", []string{".js", ".pdf", ".go"}, []string{".vue", ".png"}},
+		{"vue", []string{".vue", ".js"}, nil},
+		{"+.vue, +svelte", []string{".vue", ".svelte", ".js"}, nil},
+		{"-.pdf,-doc", []string{".js"}, []string{".pdf", ".doc"}},
+		{"+.VUE,-.PDF", []string{".vue"}, []string{".pdf", ".VUE"}},
+		{" , ,+,-", []string{".js"}, []string{"", "."}},
+		{"-.js,+.js", []string{".js"}, nil},
+		{"+.js,-.js", nil, []string{".js"}},
+	}
+	for _, tt := range tests {
+		exts := parseCodeExtensions(tt.spec)
+		for _, ext := range tt.present {
+			if !exts[ext] {
+				t.Errorf("parseCodeExtensions(%q) is missing %q", tt.spec, ext)
+			}
+		}
+		for _, ext := range tt.absent {
+			if exts[ext] {
+				t.Errorf("parseCodeExtensions(%q) includes %q", tt.spec, ext)
+			}
+		}
+	}
+
+	c := NewCrawler(nil, nil)
+	c.CodeExtensions = parseCodeExtensions("+vue,-pdf")
+	if !c.isCodeFile("https://example.com/App.Vue?x=1") {
+		t.Error("added extension not treated as code")
+	}
+	if c.isCodeFile("https://example.com/report.pdf") {
+		t.Error("removed extension still treated as code")
+	}
+}
+
+func TestFrameLinks(t *testing.T) {
+	tests := []struct {
+		name string
+		page string
+		want []string
+	}{
+		{
+			"srcdoc",
+			`<html><body>
+<iframe src="/frame-src" srcdoc="<a href='inner.html'>a</a><img src='/abs.png'><a href='https://other.example/x'>x</a>"></iframe>
+<iframe srcdoc="<iframe srcdoc=&quot;<a href=deep.html>d</a>&quot;></iframe>"></iframe>
+</body></html>`,
+			[]string{
+				"https://example.com/abs.png",
+				"https://example.com/docs/deep.html",
+				"https://example.com/docs/inner.html",
+				"https://example.com/frame-src",
+				"https://other.example/x",
+			},
+		},
+		{
+			"frameset",
+			`<html><frameset cols="20%,80%">
+<frame src="nav.html">
+<frameset rows="50%,50%"><frame src="/main.html"></frameset>
+<noframes><a href="nf.html">no frames</a> <a href="/nf-abs">here</a></noframes>
+</frameset></html>`,
+			[]string{
+				"https://example.com/docs/nav.html",
+				"https://example.com/docs/nf.html",
+				"https://example.com/main.html",
+				"https://example.com/nf-abs",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := pageLinks(t, NewCrawler(nil, nil), "https://example.com/docs/page.html", tt.page)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("links = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMediaLinks(t *testing.T) {
+	page := `<html><body>
+<video poster="/v/poster.jpg" src="movie.mp4" controls>
+  <source src="/v/movie.webm" type="video/webm">
+  <track kind="subtitles" src="subs/en.vtt" srclang="en">
+  <track kind="captions" src="/subs/fr.vtt" srclang="fr">
+</video>
+<audio src="/a/song.mp3"><source src="song.ogg" type="audio/ogg"></audio>
+<picture>
+  <source srcset="/p/large.avif 2x, /p/small.avif 1x" type="image/avif">
+  <img src="/p/fallback.jpg">
+</picture>
+</body></html>`
+	want := []string{
+		"https://example.com/a/song.mp3",
+		"https://example.com/media/movie.mp4",
+		"https://example.com/media/song.ogg",
+		"https://example.com/media/subs/en.vtt",
+		"https://example.com/p/fallback.jpg",
+		"https://example.com/p/large.avif",
+		"https://example.com/p/small.avif",
+		"https://example.com/subs/fr.vtt",
+		"https://example.com/v/movie.webm",
+		"https://example.com/v/poster.jpg",
+	}
+	got := pageLinks(t, NewCrawler(nil, nil), "https://example.com/media/page.html", page)
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("links = %q, want %q", got, want)
+	}
+}
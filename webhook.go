@@ -0,0 +1,135 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+// Kinds of Finding.
+const (
+	findingSecret      = "secret"
+	findingServerError = "server-error"
+	findingNewHost     = "new-host"
+)
+
+// Finding is something worth telling someone about while the crawl is
+// still running.
+type Finding struct {
+	Kind   string    `json:"kind"`
+	URL    string    `json:"url"`
+	Host   string    `json:"host,omitempty"`
+	Status int       `json:"status,omitempty"`
+	Detail string    `json:"detail,omitempty"`
+	Time   time.Time `json:"time"`
+}
+
+func (c *Crawler) notifyFinding(f Finding) {
+	if c.OnFinding == nil {
+		return
+	}
+	f.Time = time.Now()
+	c.OnFinding(f)
+}
+
+const (
+	// webhookBuffer is how many findings may wait for the sender before
+	// new ones are dropped rather than stalling the crawl.
+	webhookBuffer = 1024
+	// webhookMaxBatch caps the findings sent in one request.
+	webhookMaxBatch = 100
+)
+
+// webhookNotifier POSTs findings as JSON to a URL. Findings are collected
+// for Interval and sent together, so a burst of 500s is one request
+// rather than hundreds.
+type webhookNotifier struct {
+	url      string
+	client   *http.Client
+	interval time.Duration
+	findings chan Finding
+	done     chan struct{}
+	dropped  atomic.Int64
+}
+
+func newWebhookNotifier(url string, interval time.Duration) *webhookNotifier {
+	w := &webhookNotifier{
+		url:      url,
+		client:   &http.Client{Timeout: 10 * time.Second},
+		interval: interval,
+		findings: make(chan Finding, webhookBuffer),
+		done:     make(chan struct{}),
+	}
+	go w.run()
+	return w
+}
+
+// Add queues a finding. It never blocks, so a slow webhook never holds
+// up the crawl.
+func (w *webhookNotifier) Add(f Finding) {
+	select {
+	case w.findings <- f:
+	default:
+		w.dropped.Add(1)
+	}
+}
+
+func (w *webhookNotifier) run() {
+	defer close(w.done)
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	var batch []Finding
+	for {
+		select {
+		case f, ok := <-w.findings:
+			if !ok {
+				w.send(batch)
+				return
+			}
+			batch = append(batch, f)
+			if len(batch) >= webhookMaxBatch {
+				w.send(batch)
+				batch = nil
+			}
+		case <-ticker.C:
+			w.send(batch)
+			batch = nil
+		}
+	}
+}
+
+func (w *webhookNotifier) send(batch []Finding) {
+	if len(batch) == 0 {
+		return
+	}
+	payload, err := json.Marshal(struct {
+		Findings []Finding `json:"findings"`
+	}{batch})
+	if err != nil {
+		errorf("Could not encode webhook payload: %v", err)
+		return
+	}
+	resp, err := w.client.Post(w.url, "application/json", bytes.NewReader(payload))
+	if err == nil {
+		resp.Body.Close()
+		if resp.StatusCode >= 300 {
+			err = fmt.Errorf("status %d", resp.StatusCode)
+		}
+	}
+	if err != nil {
+		errorf("Could not send %d findings to webhook: %v", len(batch), err)
+	}
+}
+
+// Close sends whatever is still queued and waits for it to go out.
+func (w *webhookNotifier) Close() {
+	close(w.findings)
+	<-w.done
+	if n := w.dropped.Load(); n > 0 {
+		errorf("%d findings were not sent to the webhook because it fell behind", n)
+	}
+}
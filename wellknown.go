@@ -0,0 +1,52 @@
+package main
+
+import (
+	"net/url"
+	"strings"
+	"time"
+)
+
+// viaWellKnown tags URLs probed because every site might have them.
+const viaWellKnown = "well-known"
+
+// defaultWellKnownPaths are worth a request on any host: they describe the
+// site, point at more URLs or reveal policy files.
+var defaultWellKnownPaths = []string{
+	"/robots.txt",
+	"/sitemap.xml",
+	"/.well-known/security.txt",
+	"/.well-known/change-password",
+	"/crossdomain.xml",
+	"/manifest.json",
+	"/favicon.ico",
+}
+
+// probeWellKnown queues Crawler.WellKnown on u's origin the first time an
+// in-scope URL on that origin is queued. A path is only recorded as found
+// if it doesn't 404; other statuses end up in the non-200 list as usual.
+func (c *Crawler) probeWellKnown(u string, depth int) {
+	if len(c.WellKnown) == 0 {
+		return
+	}
+	parsed, err := url.Parse(u)
+	if err != nil || parsed.Host == "" {
+		return
+	}
+	origin := parsed.Scheme + "://" + parsed.Host
+	c.Mutex.Lock()
+	probed := c.originsProbed[origin]
+	c.originsProbed[origin] = true
+	c.Mutex.Unlock()
+	if probed {
+		return
+	}
+
+	now := time.Now()
+	for _, p := range c.WellKnown {
+		target := normalizeURL(origin + "/" + strings.TrimPrefix(p, "/"))
+		if !c.isInScope(target) {
+			continue
+		}
+		c.enqueue(QueueItem{URL: target, Source: origin + "/", Depth: depth, DiscoveredAt: now, Probe: true})
+	}
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"bufio"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+)
+
+// pathParamCollector gathers the unique in-scope paths and query parameter
+// names seen on the result stream, for feeding into ffuf or arjun.
+type pathParamCollector struct {
+	paths  map[string]bool
+	params map[string]bool
+}
+
+func newPathParamCollector() *pathParamCollector {
+	return &pathParamCollector{
+		paths:  make(map[string]bool),
+		params: make(map[string]bool),
+	}
+}
+
+func (p *pathParamCollector) Add(r URLResult) {
+	if r.Scope != "in" {
+		return
+	}
+	u, err := url.Parse(r.URL)
+	if err != nil {
+		return
+	}
+
+	path := u.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	p.paths[path] = true
+
+	// ParseQuery keeps every pair it could parse even when it reports an
+	// error for a malformed one, so the error is deliberately ignored.
+	query, _ := url.ParseQuery(u.RawQuery)
+	for name := range query {
+		if name != "" {
+			p.params[name] = true
+		}
+	}
+}
+
+func (p *pathParamCollector) WriteFiles(pathsFile, paramsFile string) error {
+	if err := writeSortedLines(pathsFile, p.paths); err != nil {
+		return err
+	}
+	return writeSortedLines(paramsFile, p.params)
+}
+
+// wordCollector builds a single wordlist from the path segments and query
+// parameter names of in-scope URLs.
+type wordCollector struct {
+	words map[string]bool
+}
+
+func newWordCollector() *wordCollector {
+	return &wordCollector{words: make(map[string]bool)}
+}
+
+func (w *wordCollector) Add(r URLResult) {
+	if r.Scope != "in" {
+		return
+	}
+	u, err := url.Parse(r.URL)
+	if err != nil {
+		return
+	}
+
+	for _, seg := range strings.Split(u.Path, "/") {
+		if seg != "" {
+			w.words[seg] = true
+		}
+	}
+	query, _ := url.ParseQuery(u.RawQuery)
+	for name := range query {
+		if name != "" {
+			w.words[name] = true
+		}
+	}
+}
+
+func (w *wordCollector) WriteFile(filename string) error {
+	return writeSortedLines(filename, w.words)
+}
+
+// readLines returns the non-empty lines of a file, ignoring # comments.
+func readLines(filename string) ([]string, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var lines []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line != "" && !strings.HasPrefix(line, "#") {
+			lines = append(lines, line)
+		}
+	}
+	return lines, scanner.Err()
+}
+
+// writeSortedLines writes the set one entry per line, sorted, with no header.
+func writeSortedLines(filename string, set map[string]bool) error {
+	lines := make([]string, 0, len(set))
+	for s := range set {
+		lines = append(lines, s)
+	}
+	sort.Strings(lines)
+
+	f, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	w := bufio.NewWriter(f)
+	for _, s := range lines {
+		w.WriteString(s + "\n")
+	}
+	return w.Flush()
+}